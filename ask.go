@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// LoadQuestions reads a JSON list of questions, in the format of a scenario's
+// "questions", from a file
+func LoadQuestions(path string) ([]Question, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read questions file: %v", err)
+	}
+	var questions []Question
+	if err := json.Unmarshal(data, &questions); err != nil {
+		return nil, fmt.Errorf("failed to parse questions file %s: %v", path, err)
+	}
+	for _, question := range questions {
+		if err := question.validate(); err != nil {
+			return nil, fmt.Errorf("questions file %s: %v", path, err)
+		}
+	}
+	return questions, nil
+}
+
+// askSimulations answers new questions against the trajectories saved in a
+// multi_sim directory, without rerunning any turns. Only simulations that
+// completed all of their turns are asked. The aggregate is written to
+// baseDir/aggregate_ask_<timestamp>.json, next to the original
+// aggregate_results.json, and its path returned.
+func askSimulations(ctx context.Context, baseDir string, scenario Scenario, questions []Question, backend LLMBackend) (string, error) {
+	if len(questions) == 0 {
+		return "", fmt.Errorf("no questions to ask")
+	}
+	questions = append([]Question(nil), questions...)
+	for i, question := range questions {
+		if question.Variable == "" {
+			continue
+		}
+		variable, ok := findVariable(scenario.Variables, question.Variable)
+		if !ok {
+			return "", fmt.Errorf("question %q reads variable %q, which the scenario doesn't declare", question.Text, question.Variable)
+		}
+		questions[i] = question.readingVariable(variable)
+	}
+	numSimulations, err := countSimulationDirs(baseDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", baseDir, err)
+	}
+	if scenario.NumSimulations > numSimulations {
+		numSimulations = scenario.NumSimulations
+	}
+	if numSimulations == 0 {
+		return "", fmt.Errorf("%s has no simulations", baseDir)
+	}
+
+	fmt.Printf("\n=== Asking %d Simulations ===\n", numSimulations)
+	fmt.Printf("Scenario: %s\n", scenario.Scenario)
+	for _, question := range questions {
+		fmt.Printf("Question: %s\n", question.Text)
+	}
+
+	type askResult struct {
+		index       int
+		result      []SimulationResult
+		err         error
+		stopped     bool // By the budget
+		interrupted bool // By cancelling ctx
+	}
+
+	recorder := NewUsageRecorder(backend, UsageReport{})
+	resultsChan := make(chan askResult, numSimulations)
+	for i := 0; i < numSimulations; i++ {
+		go func(simIndex int) {
+			simDir := filepath.Join(baseDir, fmt.Sprintf("simulation_%d", simIndex+1))
+			progress := loadSimulationProgress(simDir)
+			if progress.WorldState == nil || len(progress.Actions) < scenario.Turns {
+				resultsChan <- askResult{
+					index: simIndex,
+					err:   fmt.Errorf("simulation did not finish: %d of %d turns saved", len(progress.Actions), scenario.Turns),
+				}
+				return
+			}
+
+			// Same key as when the simulation ran, so --cache can reuse answers
+			simCtx := withSimulationKey(ctx, fmt.Sprintf("simulation_%d", simIndex+1))
+			result, err := answerQuestions(simCtx, questions, *progress.WorldState, progress.Actions, recorder)
+			if err != nil {
+				resultsChan <- askResult{
+					index:       simIndex,
+					err:         err,
+					stopped:     budgetError(backend) != nil,
+					interrupted: ctx.Err() != nil,
+				}
+				return
+			}
+			resultsChan <- askResult{index: simIndex, result: result}
+		}(i)
+	}
+
+	results := make([][]SimulationResult, numSimulations)
+	var failures []SimulationFailure
+	failed, stopped, interrupted := 0, 0, 0
+	for i := 0; i < numSimulations; i++ {
+		res := <-resultsChan
+		switch {
+		case res.interrupted:
+			interrupted++
+			failures = append(failures, SimulationFailure{Simulation: res.index + 1, Error: res.err.Error(), Interrupted: true})
+		case res.stopped:
+			stopped++
+			failures = append(failures, SimulationFailure{Simulation: res.index + 1, Error: res.err.Error(), Stopped: true})
+		case res.err != nil:
+			failed++
+			failures = append(failures, SimulationFailure{Simulation: res.index + 1, Error: res.err.Error()})
+		default:
+			results[res.index] = res.result
+		}
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Simulation < failures[j].Simulation })
+
+	shockDraws := make([][]ShockDraw, numSimulations)
+	for i, simulationResults := range results {
+		if simulationResults != nil {
+			shockDraws[i] = loadShockDraws(filepath.Join(baseDir, fmt.Sprintf("simulation_%d", i+1)))
+		}
+	}
+	aggregateResult := AggregateResult{
+		Scenario:          scenario.Scenario,
+		Turns:             scenario.Turns,
+		Total:             numSimulations,
+		Successful:        numSimulations - failed - stopped - interrupted,
+		Failed:            failed,
+		Stopped:           stopped,
+		Interrupted:       interrupted,
+		Failures:          failures,
+		Questions:         aggregateAnswers(questions, results),
+		Usage:             recorder.Report(),
+		Shocks:            breakDownByShock(scenario.Shocks, questions, shockDraws, results),
+		IndividualResults: results,
+	}
+	aggregatePath := filepath.Join(baseDir, fmt.Sprintf("aggregate_ask_%s.json", time.Now().Format("20060102_150405")))
+	aggregateJSON, _ := json.MarshalIndent(aggregateResult, "", "  ")
+	if err := ioutil.WriteFile(aggregatePath, aggregateJSON, 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %v", aggregatePath, err)
+	}
+
+	fmt.Printf("\n\n=== AGGREGATE RESULTS ===\n")
+	fmt.Printf("Simulations answered: %d of %d\n", aggregateResult.Successful, numSimulations)
+	for _, failure := range failures {
+		fmt.Printf("Simulation %d not answered: %s\n", failure.Simulation, failure.Error)
+	}
+	for _, aggregate := range aggregateResult.Questions {
+		printQuestionAggregate(aggregate)
+	}
+	for _, breakdown := range aggregateResult.Shocks {
+		printShockBreakdown(breakdown)
+	}
+	printUsage(aggregateResult.Usage)
+	fmt.Printf("\nResults saved to: %s\n", aggregatePath)
+
+	if interrupted > 0 {
+		return aggregatePath, fmt.Errorf("interrupted; %d of %d simulations answered", aggregateResult.Successful, numSimulations)
+	}
+	if stopped > 0 {
+		return aggregatePath, fmt.Errorf("%v; %d of %d simulations answered", budgetError(backend), aggregateResult.Successful, numSimulations)
+	}
+	if aggregateResult.Successful == 0 {
+		return aggregatePath, fmt.Errorf("no simulation could be answered (first error: %s)", failures[0].Error)
+	}
+	return aggregatePath, nil
+}
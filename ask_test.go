@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAskAnswersSavedSimulations(t *testing.T) {
+	baseDir := filepath.Join(t.TempDir(), "multi_sim_test")
+	scenario := testScenario(2)
+	if err := runSimulationBatch(context.Background(), scenario, 3, NewFakeBackend(), baseDir); err != nil {
+		t.Fatalf("runSimulationBatch returned error: %v", err)
+	}
+	// Simulation 3 never finished its last turn
+	if err := os.Remove(filepath.Join(baseDir, "simulation_3", "turn_2", "world_state.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	fake := NewFakeBackend()
+	fake.Responses["SummarizationAnswer"] = []string{`{"answer": "No, it did not.", "yes_no": false}`}
+	low, high := 0.0, 10.0
+	questions := []Question{
+		{Text: "new question?"},
+		{Text: "how many?", Type: QuestionNumeric, Min: &low, Max: &high},
+	}
+	path, err := askSimulations(context.Background(), baseDir, scenario, questions, fake)
+	if err != nil {
+		t.Fatalf("askSimulations returned error: %v", err)
+	}
+	if filepath.Dir(path) != baseDir || !strings.HasPrefix(filepath.Base(path), "aggregate_ask_") {
+		t.Errorf("aggregate written to %s", path)
+	}
+
+	// Only the questions are sent, once per finished simulation
+	for _, call := range fake.Calls() {
+		if call.Stage != StageAnswer {
+			t.Errorf("ask sent a %s request", call.Stage)
+		}
+	}
+	if got := fake.CallCount("SummarizationAnswer"); got != 2 {
+		t.Errorf("%d binary answers, want 2", got)
+	}
+	if got := fake.CallCount("NumericAnswer"); got != 2 {
+		t.Errorf("%d numeric answers, want 2", got)
+	}
+
+	var aggregate AggregateResult
+	readJSONFile(t, path, &aggregate)
+	if aggregate.Total != 3 || aggregate.Successful != 2 || aggregate.Failed != 1 {
+		t.Errorf("total, successful, failed = %d, %d, %d, want 3, 2, 1", aggregate.Total, aggregate.Successful, aggregate.Failed)
+	}
+	if len(aggregate.Failures) != 1 || aggregate.Failures[0].Simulation != 3 || !strings.Contains(aggregate.Failures[0].Error, "1 of 2 turns") {
+		t.Errorf("failures = %+v, want simulation 3 with 1 of 2 turns", aggregate.Failures)
+	}
+	if len(aggregate.Questions) != 2 || aggregate.Questions[0].Question != "new question?" || aggregate.Questions[0].NoCount != 2 {
+		t.Errorf("questions = %+v", aggregate.Questions)
+	}
+	if aggregate.Questions[1].Numeric == nil {
+		t.Errorf("numeric question was not summarized")
+	}
+	if aggregate.Usage.Total.Calls != 4 {
+		t.Errorf("usage counts %d calls, want 4", aggregate.Usage.Total.Calls)
+	}
+
+	// The original aggregate is left alone
+	var original AggregateResult
+	readJSONFile(t, filepath.Join(baseDir, "aggregate_results.json"), &original)
+	if original.Questions[0].Question != "question?" || original.Successful != 3 {
+		t.Errorf("original aggregate changed: %+v", original.Questions)
+	}
+}
+
+func TestAskWithoutFinishedSimulations(t *testing.T) {
+	baseDir := t.TempDir()
+	if _, err := askSimulations(context.Background(), baseDir, testScenario(1), []Question{{Text: "q?"}}, NewFakeBackend()); err == nil {
+		t.Errorf("askSimulations succeeded on a directory without simulations")
+	}
+
+	os.MkdirAll(filepath.Join(baseDir, "simulation_1"), 0755)
+	writeFile(t, filepath.Join(baseDir, "simulation_1", "actors.json"), `{"Actors": [{"name": "A", "goals": "g", "powers": "p"}]}`)
+	_, err := askSimulations(context.Background(), baseDir, testScenario(1), []Question{{Text: "q?"}}, NewFakeBackend())
+	if err == nil || !strings.Contains(err.Error(), "did not finish") {
+		t.Errorf("error = %v, want one about unfinished simulations", err)
+	}
+}
+
+func TestLoadQuestions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "questions.json")
+	writeFile(t, path, `["Yes or no?", {"text": "Which?", "type": "categorical", "options": ["a", "b"]}]`)
+	questions, err := LoadQuestions(path)
+	if err != nil {
+		t.Fatalf("LoadQuestions returned error: %v", err)
+	}
+	if len(questions) != 2 || questions[0].kind() != QuestionBinary || questions[1].kind() != QuestionCategorical {
+		t.Errorf("questions = %+v", questions)
+	}
+
+	writeFile(t, path, `[{"text": "Which?", "type": "categorical"}]`)
+	if _, err := LoadQuestions(path); err == nil {
+		t.Errorf("LoadQuestions accepted a categorical question without options")
+	}
+}
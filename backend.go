@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// LLMRequest is a single structured-output request to a language model
+type LLMRequest struct {
+	Prompt string
+	Model  string
+	Schema openai.ChatCompletionResponseFormatJSONSchema
+	Stage  string // Stage of the simulation making the request, as in ModelConfig
+	Actor  string // Actor the request is made for, if any
+}
+
+// LLMBackend answers a prompt with JSON matching the request's schema, and
+// reports the tokens it used. It gives up when ctx is cancelled. The
+// simulation only talks to language models through this interface, so it can
+// run against OpenAI, an OpenAI-compatible local server, or an in-process
+// fake.
+type LLMBackend interface {
+	FetchJSON(ctx context.Context, req LLMRequest) (string, TokenUsage, error)
+}
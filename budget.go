@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// BudgetBackend is an LLMBackend that refuses to make further calls once the
+// calls it has passed on have cost MaxCostUSD or used MaxTokens. Zero limits
+// are not enforced. Calls already in flight when the budget runs out still
+// finish, so the final spend can be slightly over.
+type BudgetBackend struct {
+	backend    LLMBackend
+	MaxCostUSD float64
+	MaxTokens  int
+
+	mu    sync.Mutex
+	spent UsageTotals
+}
+
+func NewBudgetBackend(backend LLMBackend, maxCostUSD float64, maxTokens int) *BudgetBackend {
+	return &BudgetBackend{backend: backend, MaxCostUSD: maxCostUSD, MaxTokens: maxTokens}
+}
+
+// check returns an error if the budget has run out
+func (b *BudgetBackend) check() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.MaxCostUSD > 0 && b.spent.CostUSD >= b.MaxCostUSD {
+		return fmt.Errorf("budget exceeded: spent $%.4f of --budget-usd $%.2f", b.spent.CostUSD, b.MaxCostUSD)
+	}
+	if b.MaxTokens > 0 && b.spent.Total() >= b.MaxTokens {
+		return fmt.Errorf("budget exceeded: used %d of --max-tokens %d tokens", b.spent.Total(), b.MaxTokens)
+	}
+	return nil
+}
+
+// Spent returns the usage of every call made through the backend so far
+func (b *BudgetBackend) Spent() UsageTotals {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.spent
+}
+
+func (b *BudgetBackend) FetchJSON(ctx context.Context, req LLMRequest) (string, TokenUsage, error) {
+	if err := b.check(); err != nil {
+		return "", TokenUsage{}, err
+	}
+	content, usage, err := b.backend.FetchJSON(ctx, req)
+
+	cost, priced := usage.Cost(req.Model)
+	b.mu.Lock()
+	b.spent.add(UsageCall{Model: req.Model, TokenUsage: usage, CostUSD: cost, Priced: priced}.totals())
+	b.mu.Unlock()
+	return content, usage, err
+}
+
+// budgetError returns why backend refuses further calls, if it is a
+// BudgetBackend that has run out, or nil
+func budgetError(backend LLMBackend) error {
+	if budget, ok := backend.(*BudgetBackend); ok {
+		return budget.check()
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestBudgetBackendRefusesCallsOnceSpent(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxCostUSD float64
+		maxTokens  int
+		wantCalls  int
+	}{
+		{"no limit", 0, 0, 10},
+		{"token limit", 0, 250, 3},
+		// 1000 prompt tokens of gpt-5.2 cost $0.00175
+		{"cost limit", 0.004, 0, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := NewFakeBackend()
+			fake.Usage = TokenUsage{PromptTokens: 100}
+			if tt.maxCostUSD > 0 {
+				fake.Usage = TokenUsage{PromptTokens: 1000}
+			}
+			budget := NewBudgetBackend(fake, tt.maxCostUSD, tt.maxTokens)
+
+			calls := 0
+			for i := 0; i < 10; i++ {
+				_, _, err := budget.FetchJSON(context.Background(), LLMRequest{Model: GPT5_2, Schema: openai.ChatCompletionResponseFormatJSONSchema{Name: "Actors"}})
+				if err != nil {
+					if !strings.Contains(err.Error(), "budget exceeded") {
+						t.Fatalf("unexpected error: %v", err)
+					}
+					break
+				}
+				calls++
+			}
+			if calls != tt.wantCalls || len(fake.Calls()) != tt.wantCalls {
+				t.Errorf("%d calls made, %d reached the backend; want %d", calls, len(fake.Calls()), tt.wantCalls)
+			}
+			if (budgetError(budget) != nil) != (tt.wantCalls < 10) {
+				t.Errorf("budgetError = %v", budgetError(budget))
+			}
+		})
+	}
+
+	if err := budgetError(NewFakeBackend()); err != nil {
+		t.Errorf("budgetError of a backend without a budget = %v", err)
+	}
+}
+
+func TestBudgetStopsBatch(t *testing.T) {
+	defer func(saved int) { maxFailures = saved }(maxFailures)
+	maxFailures = 0
+
+	baseDir := filepath.Join(t.TempDir(), "multi_sim_test")
+	fake := NewFakeBackend()
+	fake.Usage = TokenUsage{PromptTokens: 100}
+	budget := NewBudgetBackend(fake, 0, 0)
+
+	// Run one simulation, then set the budget to what it used, so that
+	// extending the batch runs out before the new simulations start
+	if err := runSimulationBatch(context.Background(), testScenario(1), 1, budget, baseDir); err != nil {
+		t.Fatalf("runSimulationBatch returned error: %v", err)
+	}
+	budget.MaxTokens = budget.Spent().Total()
+
+	err := resumeMultipleSimulations(context.Background(), baseDir, testScenario(1), 3, budget)
+	if err == nil || !strings.Contains(err.Error(), "budget exceeded") || !strings.Contains(err.Error(), "--resume") {
+		t.Fatalf("error = %v, want a budget error suggesting --resume", err)
+	}
+	var aggregate AggregateResult
+	readJSONFile(t, filepath.Join(baseDir, "aggregate_results.json"), &aggregate)
+	if aggregate.Successful != 1 || aggregate.Failed != 0 || aggregate.Stopped != 2 || len(aggregate.Failures) != 2 {
+		t.Fatalf("unexpected aggregate: %+v", aggregate)
+	}
+	for _, failure := range aggregate.Failures {
+		if !failure.Stopped {
+			t.Errorf("simulation %d not marked as stopped: %+v", failure.Simulation, failure)
+		}
+	}
+
+	// A bigger budget finishes the rest
+	if err := resumeMultipleSimulations(context.Background(), baseDir, testScenario(1), 3, NewFakeBackend()); err != nil {
+		t.Fatalf("resumeMultipleSimulations returned error: %v", err)
+	}
+	var resumed AggregateResult
+	readJSONFile(t, filepath.Join(baseDir, "aggregate_results.json"), &resumed)
+	if resumed.Successful != 3 || resumed.Stopped != 0 || len(resumed.Failures) != 0 {
+		t.Errorf("aggregate after resume: %d successful, %d stopped; want 3, 0", resumed.Successful, resumed.Stopped)
+	}
+}
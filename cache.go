@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Cache modes for --cache
+const (
+	CacheOff    = "off"    // Always call the model
+	CacheRead   = "read"   // Reuse cached replies, call the model and cache the reply on a miss
+	CacheWrite  = "write"  // Always call the model, and cache (or overwrite) the reply
+	CacheReplay = "replay" // Only use cached replies; a miss is an error
+)
+
+// CachedResponse is what gets saved for each request in the cache directory
+type CachedResponse struct {
+	Model      string     `json:"model"`
+	Schema     string     `json:"schema"`
+	Simulation string     `json:"simulation,omitempty"`
+	Prompt     string     `json:"prompt"`
+	Response   string     `json:"response"`
+	Usage      TokenUsage `json:"usage"` // What the reply cost when it was recorded
+}
+
+type simulationKey struct{}
+
+// withSimulationKey marks requests made with ctx as belonging to the given
+// simulation. Simulations of a batch send identical prompts at first (e.g. to
+// generate actors), and without this they would all get the same cached reply
+// and play out identically.
+func withSimulationKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, simulationKey{}, key)
+}
+
+func simulationKeyOf(ctx context.Context) string {
+	key, _ := ctx.Value(simulationKey{}).(string)
+	return key
+}
+
+// CachingBackend is an LLMBackend that stores replies on disk, content
+// addressed by model, schema name, simulation and prompt, so runs can be
+// replayed exactly and only the requests that changed are sent again
+type CachingBackend struct {
+	backend LLMBackend
+	dir     string
+	mode    string
+}
+
+// NewCachingBackend caches the replies of backend in dir according to mode,
+// one of CacheOff, CacheRead, CacheWrite and CacheReplay
+func NewCachingBackend(backend LLMBackend, dir string, mode string) (*CachingBackend, error) {
+	switch mode {
+	case CacheOff, CacheRead, CacheWrite, CacheReplay:
+	default:
+		return nil, fmt.Errorf("unknown cache mode %q (want off, read, write or replay)", mode)
+	}
+	return &CachingBackend{backend: backend, dir: dir, mode: mode}, nil
+}
+
+// cacheKey hashes everything that determines a request's reply
+func cacheKey(model string, schema string, simulation string, prompt string) string {
+	hash := sha256.New()
+	for _, part := range []string{model, schema, simulation, prompt} {
+		fmt.Fprintf(hash, "%d:%s\n", len(part), part)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (c *CachingBackend) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+func (c *CachingBackend) FetchJSON(ctx context.Context, req LLMRequest) (string, TokenUsage, error) {
+	if c.mode == CacheOff {
+		return c.backend.FetchJSON(ctx, req)
+	}
+
+	simulation := simulationKeyOf(ctx)
+	key := cacheKey(req.Model, req.Schema.Name, simulation, req.Prompt)
+	if c.mode == CacheRead || c.mode == CacheReplay {
+		var cached CachedResponse
+		if err := readJSON(c.path(key), &cached); err == nil {
+			// Cached replies cost nothing
+			return cached.Response, TokenUsage{}, nil
+		}
+		if c.mode == CacheReplay {
+			return "", TokenUsage{}, fmt.Errorf("no cached reply for %s request with model %s in %s (--cache replay)", req.Schema.Name, req.Model, c.dir)
+		}
+	}
+
+	response, usage, err := c.backend.FetchJSON(ctx, req)
+	if err != nil {
+		return response, usage, err
+	}
+	cached := CachedResponse{
+		Model:      req.Model,
+		Schema:     req.Schema.Name,
+		Simulation: simulation,
+		Prompt:     req.Prompt,
+		Response:   response,
+		Usage:      usage,
+	}
+	if err := c.save(key, cached); err != nil {
+		return "", usage, fmt.Errorf("failed to cache reply: %v", err)
+	}
+	return response, usage, nil
+}
+
+// save writes an entry to a temporary file first, so that parallel
+// simulations never see half of it
+func (c *CachingBackend) save(key string, cached CachedResponse) error {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(cached, "", "  ")
+	tmp, err := ioutil.TempFile(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestCachingBackendModes(t *testing.T) {
+	request := LLMRequest{Prompt: "prompt", Model: GPT5_2, Schema: openai.ChatCompletionResponseFormatJSONSchema{Name: "WorldState"}}
+	tests := []struct {
+		mode         string
+		cached       bool // Whether the request was made before, in read mode
+		wantCalls    int
+		wantResponse string
+		wantErr      bool
+	}{
+		{CacheOff, true, 1, "second", false},
+		{CacheRead, true, 0, "first", false},
+		{CacheRead, false, 1, "second", false},
+		{CacheWrite, true, 1, "second", false},
+		{CacheReplay, true, 0, "first", false},
+		{CacheReplay, false, 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.cached {
+				first := NewFakeBackend()
+				first.Responses["WorldState"] = []string{"first"}
+				cache, _ := NewCachingBackend(first, dir, CacheRead)
+				if _, _, err := cache.FetchJSON(context.Background(), request); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			second := NewFakeBackend()
+			second.Responses["WorldState"] = []string{"second"}
+			second.Usage = TokenUsage{PromptTokens: 10}
+			cache, err := NewCachingBackend(second, dir, tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response, usage, err := cache.FetchJSON(context.Background(), request)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error: %v", err, tt.wantErr)
+			}
+			if response != tt.wantResponse {
+				t.Errorf("response = %q, want %q", response, tt.wantResponse)
+			}
+			if got := len(second.Calls()); got != tt.wantCalls {
+				t.Errorf("%d calls reached the backend, want %d", got, tt.wantCalls)
+			}
+			// Only calls that reached the model cost anything
+			if wantTokens := 10 * tt.wantCalls; usage.Total() != wantTokens {
+				t.Errorf("usage = %d tokens, want %d", usage.Total(), wantTokens)
+			}
+		})
+	}
+
+	if _, err := NewCachingBackend(NewFakeBackend(), t.TempDir(), "sometimes"); err == nil {
+		t.Errorf("NewCachingBackend accepted an unknown mode")
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	key := cacheKey(GPT5_2, "Actors", "simulation_1", "prompt")
+	for _, other := range []string{
+		cacheKey(GPT5, "Actors", "simulation_1", "prompt"),
+		cacheKey(GPT5_2, "WorldState", "simulation_1", "prompt"),
+		cacheKey(GPT5_2, "Actors", "simulation_2", "prompt"),
+		cacheKey(GPT5_2, "Actors", "simulation_1", "prompt 2"),
+		// Parts can't run into each other
+		cacheKey(GPT5_2, "Actorssimulation_1", "", "prompt"),
+	} {
+		if other == key {
+			t.Errorf("different requests share the key %s", key)
+		}
+	}
+	if cacheKey(GPT5_2, "Actors", "simulation_1", "prompt") != key {
+		t.Errorf("cacheKey is not deterministic")
+	}
+}
+
+func TestCachedBatchOnlyAsksNewQuestions(t *testing.T) {
+	cacheDir := t.TempDir()
+	scenario := testScenario(2)
+
+	// Each simulation generates different actors, so a cache that mixed up
+	// simulations would show up as identical trajectories
+	fake := NewFakeBackend()
+	fake.Responses["Actors"] = []string{
+		`{"Actors": [{"name": "Actor A", "goals": "g", "powers": "p"}], "observations": "1"}`,
+		`{"Actors": [{"name": "Actor B", "goals": "g", "powers": "p"}], "observations": "2"}`,
+	}
+	cache, _ := NewCachingBackend(fake, cacheDir, CacheRead)
+	firstDir := filepath.Join(t.TempDir(), "multi_sim_first")
+	if err := runSimulationBatch(context.Background(), scenario, 2, cache, firstDir); err != nil {
+		t.Fatalf("runSimulationBatch returned error: %v", err)
+	}
+
+	// Rerun with another question: only the answers are new
+	scenario.Question = "another question?"
+	rerun := NewFakeBackend()
+	cache, _ = NewCachingBackend(rerun, cacheDir, CacheRead)
+	secondDir := filepath.Join(t.TempDir(), "multi_sim_second")
+	if err := runSimulationBatch(context.Background(), scenario, 2, cache, secondDir); err != nil {
+		t.Fatalf("runSimulationBatch returned error: %v", err)
+	}
+	for _, call := range rerun.Calls() {
+		if call.Stage != StageAnswer {
+			t.Errorf("rerun sent a %s request", call.Stage)
+		}
+	}
+	if got := rerun.CallCount("SummarizationAnswer"); got != 2 {
+		t.Errorf("rerun answered %d questions, want 2", got)
+	}
+
+	// Both simulations replay exactly
+	for _, sim := range []string{"simulation_1", "simulation_2"} {
+		var first, second Actors
+		readJSONFile(t, filepath.Join(firstDir, sim, "actors.json"), &first)
+		readJSONFile(t, filepath.Join(secondDir, sim, "actors.json"), &second)
+		if first.Observations != second.Observations {
+			t.Errorf("%s: actors differ between runs: %q, %q", sim, first.Observations, second.Observations)
+		}
+	}
+
+	// Replaying a question that was never asked fails
+	scenario.Question = "a third question?"
+	cache, _ = NewCachingBackend(NewFakeBackend(), cacheDir, CacheReplay)
+	err := runSimulationBatch(context.Background(), scenario, 2, cache, filepath.Join(t.TempDir(), "multi_sim_third"))
+	if err == nil || !strings.Contains(err.Error(), "replay") {
+		t.Errorf("error = %v, want a replay cache miss", err)
+	}
+}
+
+func TestCachedBatchReplaysShocks(t *testing.T) {
+	cacheDir := t.TempDir()
+	scenario := testScenario(3)
+	scenario.Shocks = []Shock{
+		{Name: "Quake", Event: "An earthquake hits Tokyo", Probability: floatPtr(0.5)},
+		{Name: "CPI", Event: "CPI comes in far above expectations"}, // Estimated by the model
+	}
+	scenario = scenario.seeded()
+
+	fake := NewFakeBackend()
+	fake.Responses["ShockEstimates"] = []string{`{"estimates": [{"shock": "CPI", "probability": 0.5, "reasoning": "r"}]}`}
+	cache, _ := NewCachingBackend(fake, cacheDir, CacheWrite)
+	firstDir := filepath.Join(t.TempDir(), "multi_sim_first")
+	if err := runSimulationBatch(context.Background(), scenario, 4, cache, firstDir); err != nil {
+		t.Fatalf("runSimulationBatch returned error: %v", err)
+	}
+
+	// Rerun from the saved scenario.json, without calling the model
+	saved, err := LoadScenario(filepath.Join(firstDir, "scenario.json"))
+	if err != nil {
+		t.Fatalf("LoadScenario returned error: %v", err)
+	}
+	if saved.Seed != scenario.Seed {
+		t.Errorf("scenario.json has seed %d, want %d", saved.Seed, scenario.Seed)
+	}
+	cache, _ = NewCachingBackend(NewFakeBackend(), cacheDir, CacheReplay)
+	secondDir := filepath.Join(t.TempDir(), "multi_sim_second")
+	if err := runSimulationBatch(context.Background(), saved, 4, cache, secondDir); err != nil {
+		t.Fatalf("replaying a batch with shocks failed: %v", err)
+	}
+
+	for sim := 1; sim <= 4; sim++ {
+		simDir := fmt.Sprintf("simulation_%d", sim)
+		first, second := loadShockDraws(filepath.Join(firstDir, simDir)), loadShockDraws(filepath.Join(secondDir, simDir))
+		if len(first) != 6 || !reflect.DeepEqual(first, second) {
+			t.Errorf("%s: draws differ between runs:\n%+v\n%+v", simDir, first, second)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Calendar maps turns to simulated dates. The zero Calendar has no dates, for
+// scenarios that don't declare a start date.
+type Calendar struct {
+	Start  time.Time
+	Days   int // Length of a turn is Days + Months
+	Months int
+}
+
+// Turn identifies a simulation turn and the simulated time it covers
+type Turn struct {
+	Number       int           // From 1
+	Date         string        // Simulated date at the start of the turn, YYYY-MM-DD; empty without a calendar
+	EndDate      string        // Simulated date at the end of the turn, which the updated world state is dated
+	Negotiations []Negotiation // Held during the turn
+	Variables    []Variable    // Tracked in the world state
+	Shocks       []Shock       // That can fire at the start of the turn
+}
+
+// parseTurnDuration reads durations like "1 week", "3 days" or "2 months"
+func parseTurnDuration(s string) (days int, months int, err error) {
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("turn duration %q is not a number and a unit, e.g. \"1 week\"", s)
+	}
+	n, err := strconv.Atoi(fields[0])
+	if err != nil || n < 1 {
+		return 0, 0, fmt.Errorf("turn duration %q does not start with a positive whole number", s)
+	}
+	switch strings.TrimSuffix(fields[1], "s") {
+	case "day":
+		return n, 0, nil
+	case "week":
+		return 7 * n, 0, nil
+	case "month":
+		return 0, n, nil
+	case "quarter":
+		return 0, 3 * n, nil
+	case "year":
+		return 0, 12 * n, nil
+	}
+	return 0, 0, fmt.Errorf("turn duration %q has an unknown unit (want days, weeks, months, quarters or years)", s)
+}
+
+// Calendar returns the scenario's calendar, which is the zero Calendar if it
+// has no start date
+func (s Scenario) Calendar() (Calendar, error) {
+	if s.StartDate == "" && s.TurnDuration == "" {
+		return Calendar{}, nil
+	}
+	if s.StartDate == "" || s.TurnDuration == "" {
+		return Calendar{}, fmt.Errorf("start_date and turn_duration must be given together")
+	}
+	start, err := time.Parse(dateLayout, s.StartDate)
+	if err != nil {
+		return Calendar{}, fmt.Errorf("start_date %q is not formatted as YYYY-MM-DD", s.StartDate)
+	}
+	days, months, err := parseTurnDuration(s.TurnDuration)
+	if err != nil {
+		return Calendar{}, err
+	}
+	return Calendar{Start: start, Days: days, Months: months}, nil
+}
+
+// dated reports whether the calendar has dates at all
+func (c Calendar) dated() bool {
+	return !c.Start.IsZero()
+}
+
+// date returns the simulated date after the given number of turns. Dates are
+// counted from the start, so month lengths don't accumulate errors.
+func (c Calendar) date(turns int) string {
+	if !c.dated() {
+		return ""
+	}
+	return c.Start.AddDate(0, c.Months*turns, c.Days*turns).Format(dateLayout)
+}
+
+// Turn returns turn n, from 1, with its dates
+func (c Calendar) Turn(n int) Turn {
+	return Turn{Number: n, Date: c.date(n - 1), EndDate: c.date(n)}
+}
+
+// turnDates describes the dates of a turn for headings, e.g. " (2026-01-01 to
+// 2026-01-08)", or returns "" without a calendar
+func turnDates(turn Turn) string {
+	if turn.Date == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (%s to %s)", turn.Date, turn.EndDate)
+}
+
+// turn returns turn n of the scenario, with its dates and negotiations
+func (s Scenario) turn(calendar Calendar, n int) Turn {
+	turn := calendar.Turn(n)
+	turn.Negotiations = s.negotiationsOn(n)
+	turn.Variables = s.Variables
+	turn.Shocks = s.shocksOn(n)
+	return turn
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTurnDuration(t *testing.T) {
+	tests := []struct {
+		input        string
+		days, months int
+		wantErr      bool
+	}{
+		{"1 day", 1, 0, false},
+		{"3 days", 3, 0, false},
+		{"2 Weeks", 14, 0, false},
+		{"1 month", 0, 1, false},
+		{"1 quarter", 0, 3, false},
+		{"2 years", 0, 24, false},
+		{"week", 0, 0, true},
+		{"0 days", 0, 0, true},
+		{"1.5 weeks", 0, 0, true},
+		{"1 fortnight", 0, 0, true},
+	}
+	for _, tt := range tests {
+		days, months, err := parseTurnDuration(tt.input)
+		if (err != nil) != tt.wantErr || days != tt.days || months != tt.months {
+			t.Errorf("parseTurnDuration(%q) = %d, %d, %v, want %d, %d, error %v", tt.input, days, months, err, tt.days, tt.months, tt.wantErr)
+		}
+	}
+}
+
+func TestCalendarTurns(t *testing.T) {
+	tests := []struct {
+		start, duration string
+		turn            int
+		want            Turn
+	}{
+		{"2026-01-01", "1 week", 1, Turn{Number: 1, Date: "2026-01-01", EndDate: "2026-01-08"}},
+		{"2026-01-01", "1 week", 3, Turn{Number: 3, Date: "2026-01-15", EndDate: "2026-01-22"}},
+		// Months are counted from the start date, so a short month doesn't shift later turns
+		{"2026-01-31", "1 month", 1, Turn{Number: 1, Date: "2026-01-31", EndDate: "2026-03-03"}},
+		{"2026-01-31", "1 month", 3, Turn{Number: 3, Date: "2026-03-31", EndDate: "2026-05-01"}},
+		{"2026-03-15", "1 quarter", 4, Turn{Number: 4, Date: "2026-12-15", EndDate: "2027-03-15"}},
+	}
+	for _, tt := range tests {
+		calendar, err := Scenario{StartDate: tt.start, TurnDuration: tt.duration}.Calendar()
+		if err != nil {
+			t.Fatalf("Calendar returned error: %v", err)
+		}
+		if got := calendar.Turn(tt.turn); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("turn %d from %s every %s = %+v, want %+v", tt.turn, tt.start, tt.duration, got, tt.want)
+		}
+	}
+
+	// Without a start date turns have no dates
+	calendar, err := testScenario(2).Calendar()
+	if err != nil || !reflect.DeepEqual(calendar.Turn(2), Turn{Number: 2}) {
+		t.Errorf("undated calendar gave %+v, %v", calendar.Turn(2), err)
+	}
+}
+
+func TestSimulationWithCalendar(t *testing.T) {
+	saveDir := t.TempDir()
+	scenario := testScenario(2)
+	scenario.StartDate = "2026-01-01"
+	scenario.TurnDuration = "2 weeks"
+	scenario.Question = ""
+	scenario.Questions = []Question{{Text: "by March?", Deadline: "2026-03-01"}}
+	fake := NewFakeBackend()
+	// The model's date is ignored in favour of the calendar's
+	fake.Responses["WorldState"] = []string{`{"events": ["[2026-01-01] event"], "description": "world", "date": "1999-01-01"}`}
+	if _, err := runSingleSimulation(context.Background(), scenario, fake, saveDir, discardLogger{}); err != nil {
+		t.Fatalf("runSingleSimulation returned error: %v", err)
+	}
+
+	for file, want := range map[string]string{
+		"initial_world_state.json": "2026-01-01",
+		"turn_1/world_state.json":  "2026-01-15",
+		"turn_2/world_state.json":  "2026-01-29",
+	} {
+		var worldState WorldState
+		readJSONFile(t, filepath.Join(saveDir, file), &worldState)
+		if worldState.Date != want {
+			t.Errorf("%s dated %q, want %q", file, worldState.Date, want)
+		}
+	}
+
+	prompts := make(map[string][]string)
+	for _, call := range fake.Calls() {
+		prompts[call.Schema.Name] = append(prompts[call.Schema.Name], call.Prompt)
+	}
+	for _, check := range []struct {
+		schema, want string
+	}{
+		{"WorldState", "The simulation starts on 2026-01-01, and each turn covers 2 weeks"},
+		{"WorldState", "between 2026-01-15 and 2026-01-29"},
+		{"ActorView", "The current simulated date is 2026-01-15"},
+		{"ActorAction", "this turn, which ends on 2026-01-29"},
+		{"SummarizationAnswer", "The simulation ends on 2026-01-29"},
+		{"SummarizationAnswer", "what happens between 2026-01-29 and 2026-03-01 is not known"},
+	} {
+		found := false
+		for _, prompt := range prompts[check.schema] {
+			found = found || strings.Contains(prompt, check.want)
+		}
+		if !found {
+			t.Errorf("no %s prompt contains %q", check.schema, check.want)
+		}
+	}
+}
+
+func TestUndatedPromptsAreUnchanged(t *testing.T) {
+	fake := NewFakeBackend()
+	if _, err := runSingleSimulation(context.Background(), testScenario(1), fake, "", discardLogger{}); err != nil {
+		t.Fatalf("runSingleSimulation returned error: %v", err)
+	}
+	for _, call := range fake.Calls() {
+		if strings.Contains(call.Prompt, "simulated date") || strings.Contains(call.Prompt, "YYYY-MM-DD") {
+			t.Errorf("%s prompt mentions dates without a calendar", call.Schema.Name)
+		}
+	}
+}
+
+func TestQuestionWithDeadlineKeepsItsFields(t *testing.T) {
+	data, err := json.Marshal([]Question{{Text: "plain?"}, {Text: "by when?", Deadline: "2026-06-30"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `["plain?",{"text":"by when?","deadline":"2026-06-30"}]`; string(data) != want {
+		t.Errorf("marshalled %s, want %s", data, want)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// FakeBackend is a deterministic, in-process LLMBackend that returns canned
+// JSON keyed by schema name ("Actors", "WorldState", "ActorView", ...). It
+// never touches the network, so the whole simulation pipeline can be
+// exercised for free.
+type FakeBackend struct {
+	// Responses holds the replies for each schema name. Replies are handed out
+	// in order and the last one is repeated once they run out.
+	Responses map[string][]string
+	// Errors makes every request for the given schema name fail
+	Errors map[string]error
+	// Handler, if set, answers every request instead of Responses and Errors
+	Handler func(req LLMRequest) (string, error)
+	// Usage is reported for every successful request
+	Usage TokenUsage
+
+	mu    sync.Mutex
+	calls []LLMRequest
+}
+
+// Canned replies used by NewFakeBackend, one per schema in main.go
+var defaultFakeResponses = map[string][]string{
+	"Actors":              {`{"Actors": [{"name": "Actor A", "goals": "Goals of A", "powers": "Powers of A"}, {"name": "Actor B", "goals": "Goals of B", "powers": "Powers of B"}], "observations": "fake observations"}`},
+	"WorldState":          {`{"events": ["event 1", "event 2"], "description": "fake world state"}`},
+	"ActorView":           {`{"visible_events": ["event 1"], "interpretation": "fake interpretation"}`},
+	"ActorAction":         {`{"actor_name": "Actor", "action": "fake action", "reasoning": "fake reasoning"}`},
+	"SummarizationAnswer": {`{"answer": "fake answer", "yes_no": true}`},
+	"NumericAnswer":       {`{"answer": "fake answer", "value": 1}`},
+	"CategoricalAnswer":   {`{"answer": "fake answer", "choice": "A"}`},
+	"DateAnswer":          {`{"answer": "fake answer", "date": "2026-01-01"}`},
+	"NegotiationMove":     {`{"party": "Actor", "round": 1, "statement": "fake statement", "proposal": "fake terms", "accept": true, "walk_away": false}`},
+	"ShockEstimates":      {`{"estimates": [{"shock": "Shock", "probability": 0.5, "reasoning": "fake reasoning"}]}`},
+}
+
+// NewFakeBackend creates a FakeBackend that gives a valid reply for every
+// schema used by the simulation. Tests override entries as needed.
+func NewFakeBackend() *FakeBackend {
+	responses := make(map[string][]string)
+	for name, replies := range defaultFakeResponses {
+		responses[name] = append([]string(nil), replies...)
+	}
+	return &FakeBackend{
+		Responses: responses,
+		Errors:    make(map[string]error),
+	}
+}
+
+func (f *FakeBackend) FetchJSON(ctx context.Context, req LLMRequest) (string, TokenUsage, error) {
+	if err := ctx.Err(); err != nil {
+		return "", TokenUsage{}, err
+	}
+	content, err := f.reply(req)
+	if err != nil {
+		return "", TokenUsage{}, err
+	}
+	return content, f.Usage, nil
+}
+
+func (f *FakeBackend) reply(req LLMRequest) (string, error) {
+	f.mu.Lock()
+	name := req.Schema.Name
+	callIndex := 0
+	for _, call := range f.calls {
+		if call.Schema.Name == name {
+			callIndex++
+		}
+	}
+	f.calls = append(f.calls, req)
+	handler := f.Handler
+	f.mu.Unlock()
+
+	// Handlers may block (e.g. to check that calls run concurrently), so they
+	// are called without holding the lock
+	if handler != nil {
+		return handler(req)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err, ok := f.Errors[name]; ok && err != nil {
+		return "", err
+	}
+	replies := f.Responses[name]
+	if len(replies) == 0 {
+		return "", fmt.Errorf("fake backend: no response for schema %q", name)
+	}
+	if callIndex >= len(replies) {
+		callIndex = len(replies) - 1
+	}
+	return replies[callIndex], nil
+}
+
+// Calls returns every request received so far, in arrival order
+func (f *FakeBackend) Calls() []LLMRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]LLMRequest(nil), f.calls...)
+}
+
+// CallCount returns how many requests used the given schema name
+func (f *FakeBackend) CallCount(name string) int {
+	count := 0
+	for _, call := range f.Calls() {
+		if call.Schema.Name == name {
+			count++
+		}
+	}
+	return count
+}
@@ -5,4 +5,5 @@ go 1.23.4
 require (
 	github.com/joho/godotenv v1.5.1
 	github.com/sashabaranov/go-openai v1.41.1
+	gopkg.in/yaml.v3 v3.0.1
 )
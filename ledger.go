@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Path of the forecast ledger, set from --ledger; empty to not record forecasts
+var ledgerPath string
+
+// Forecast is the probability a batch of simulations gave to a yes/no question
+type Forecast struct {
+	ID          int     `json:"id"`
+	Question    string  `json:"question"`
+	Date        string  `json:"date"` // When the forecast was made, YYYY-MM-DD
+	Probability float64 `json:"probability"`
+	Source      string  `json:"source"` // Aggregate file the forecast comes from
+	Simulations int     `json:"simulations"`
+}
+
+// Resolution records whether a forecast question turned out yes or no
+type Resolution struct {
+	ID      int    `json:"id"`
+	Outcome bool   `json:"outcome"`
+	Date    string `json:"date"` // When it was resolved, YYYY-MM-DD
+}
+
+// ledgerLine is one line of the ledger file, holding one of its fields. The
+// file is only ever appended to; a later line for the same ID replaces an
+// earlier one.
+type ledgerLine struct {
+	Forecast   *Forecast   `json:"forecast,omitempty"`
+	Resolution *Resolution `json:"resolution,omitempty"`
+}
+
+// Ledger is a JSONL file of forecasts and their resolutions, so forecasts
+// made over time can be scored once the questions resolve
+type Ledger struct {
+	path        string
+	Forecasts   []Forecast // In ID order
+	Resolutions map[int]Resolution
+}
+
+// LoadLedger reads the ledger at path. A missing file is an empty ledger.
+func LoadLedger(path string) (*Ledger, error) {
+	ledger := &Ledger{path: path, Resolutions: make(map[int]Resolution)}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return ledger, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ledger: %v", err)
+	}
+	defer file.Close()
+
+	byID := make(map[int]Forecast)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var line ledgerLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("failed to parse %s line %d: %v", path, lineNumber, err)
+		}
+		if line.Forecast != nil {
+			byID[line.Forecast.ID] = *line.Forecast
+		}
+		if line.Resolution != nil {
+			ledger.Resolutions[line.Resolution.ID] = *line.Resolution
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ledger: %v", err)
+	}
+
+	for _, forecast := range byID {
+		ledger.Forecasts = append(ledger.Forecasts, forecast)
+	}
+	sort.Slice(ledger.Forecasts, func(i, j int) bool { return ledger.Forecasts[i].ID < ledger.Forecasts[j].ID })
+	return ledger, nil
+}
+
+func (l *Ledger) append(line ledgerLine) error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open ledger: %v", err)
+	}
+	data, _ := json.Marshal(line)
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write ledger: %v", err)
+	}
+	return file.Close()
+}
+
+// Forecast returns the forecast with the given ID
+func (l *Ledger) Forecast(id int) (Forecast, bool) {
+	for _, forecast := range l.Forecasts {
+		if forecast.ID == id {
+			return forecast, true
+		}
+	}
+	return Forecast{}, false
+}
+
+// Register adds a forecast to the ledger and returns it with its ID. A
+// forecast of the same question from the same source, e.g. after resuming a
+// batch, replaces the earlier one.
+func (l *Ledger) Register(forecast Forecast) (Forecast, error) {
+	forecast.ID = 0
+	highest := 0
+	for _, existing := range l.Forecasts {
+		if existing.Source == forecast.Source && existing.Question == forecast.Question {
+			forecast.ID = existing.ID
+		}
+		if existing.ID > highest {
+			highest = existing.ID
+		}
+	}
+	replacing := forecast.ID != 0
+	if !replacing {
+		forecast.ID = highest + 1
+	}
+
+	if err := l.append(ledgerLine{Forecast: &forecast}); err != nil {
+		return Forecast{}, err
+	}
+	if replacing {
+		for i := range l.Forecasts {
+			if l.Forecasts[i].ID == forecast.ID {
+				l.Forecasts[i] = forecast
+			}
+		}
+	} else {
+		l.Forecasts = append(l.Forecasts, forecast)
+	}
+	return forecast, nil
+}
+
+// Resolve records the outcome of the forecast with the given ID. Resolving a
+// forecast again corrects its outcome.
+func (l *Ledger) Resolve(id int, outcome bool, date time.Time) error {
+	if _, ok := l.Forecast(id); !ok {
+		return fmt.Errorf("no forecast %d in %s", id, l.path)
+	}
+	resolution := Resolution{ID: id, Outcome: outcome, Date: date.Format(dateLayout)}
+	if err := l.append(ledgerLine{Resolution: &resolution}); err != nil {
+		return err
+	}
+	l.Resolutions[id] = resolution
+	return nil
+}
+
+// registerAggregate records a forecast for every yes/no question in the
+// aggregate file at path, made on date
+func registerAggregate(ledger *Ledger, path string, date time.Time) ([]Forecast, error) {
+	var aggregate AggregateResult
+	if err := readJSON(path, &aggregate); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	var forecasts []Forecast
+	for _, question := range aggregate.Questions {
+		if question.Type != QuestionBinary || question.MeanProbability == nil {
+			continue
+		}
+		forecast, err := ledger.Register(Forecast{
+			Question:    question.Question,
+			Date:        date.Format(dateLayout),
+			Probability: *question.MeanProbability,
+			Source:      path,
+			Simulations: aggregate.Successful,
+		})
+		if err != nil {
+			return forecasts, err
+		}
+		forecasts = append(forecasts, forecast)
+	}
+	return forecasts, nil
+}
+
+// recordForecasts registers the forecasts of a finished run in the ledger at
+// ledgerPath, if there is one. A failure is reported but doesn't fail the run.
+func recordForecasts(aggregatePath string) {
+	if ledgerPath == "" {
+		return
+	}
+	ledger, err := LoadLedger(ledgerPath)
+	if err == nil {
+		var forecasts []Forecast
+		forecasts, err = registerAggregate(ledger, aggregatePath, time.Now())
+		for _, forecast := range forecasts {
+			fmt.Printf("Recorded forecast %d in %s: %.1f%% for %q\n", forecast.ID, ledgerPath, forecast.Probability*100, forecast.Question)
+		}
+	}
+	if err != nil {
+		fmt.Printf("Warning: failed to record forecasts in %s: %v\n", ledgerPath, err)
+	}
+}
+
+// CalibrationBin compares the forecasts in [Low, High) with how often their
+// questions resolved yes
+type CalibrationBin struct {
+	Low             float64 `json:"low"`
+	High            float64 `json:"high"`
+	Count           int     `json:"count"`
+	MeanProbability float64 `json:"mean_probability"`
+	YesFrequency    float64 `json:"yes_frequency"`
+}
+
+// ForecastScores summarizes how well the resolved forecasts of a ledger did
+type ForecastScores struct {
+	Resolved    int              `json:"resolved"`
+	Brier       float64          `json:"brier"`     // Mean squared error; 0 is perfect, 0.25 is always saying 50%
+	LogScore    float64          `json:"log_score"` // Mean natural log of the probability given to the outcome; 0 is perfect
+	Calibration []CalibrationBin `json:"calibration"`
+}
+
+// Probabilities are kept this far from 0 and 1 in log scores, so that one
+// confident miss doesn't make the score infinite
+const logScoreFloor = 0.001
+
+// Number of bins in calibration tables
+const calibrationBins = 10
+
+// scoreForecasts computes the Brier score, log score and calibration table of
+// the resolved forecasts in the ledger
+func scoreForecasts(ledger *Ledger) ForecastScores {
+	scores := ForecastScores{Calibration: make([]CalibrationBin, calibrationBins)}
+	for i := range scores.Calibration {
+		scores.Calibration[i].Low = float64(i) / calibrationBins
+		scores.Calibration[i].High = float64(i+1) / calibrationBins
+	}
+
+	brierSum, logSum := 0.0, 0.0
+	yesCounts := make([]int, calibrationBins)
+	for _, forecast := range ledger.Forecasts {
+		resolution, ok := ledger.Resolutions[forecast.ID]
+		if !ok {
+			continue
+		}
+		p := forecast.Probability
+		outcome, probabilityOfOutcome := 0.0, 1-p
+		if resolution.Outcome {
+			outcome, probabilityOfOutcome = 1, p
+		}
+		scores.Resolved++
+		brierSum += (p - outcome) * (p - outcome)
+		logSum += math.Log(math.Min(math.Max(probabilityOfOutcome, logScoreFloor), 1-logScoreFloor))
+
+		// A probability of exactly 1 goes in the last bin
+		bin := int(p * calibrationBins)
+		if bin >= calibrationBins {
+			bin = calibrationBins - 1
+		}
+		if bin < 0 {
+			bin = 0
+		}
+		scores.Calibration[bin].Count++
+		scores.Calibration[bin].MeanProbability += p
+		if resolution.Outcome {
+			yesCounts[bin]++
+		}
+	}
+	if scores.Resolved > 0 {
+		scores.Brier = brierSum / float64(scores.Resolved)
+		scores.LogScore = logSum / float64(scores.Resolved)
+	}
+	for i := range scores.Calibration {
+		if bin := &scores.Calibration[i]; bin.Count > 0 {
+			bin.MeanProbability /= float64(bin.Count)
+			bin.YesFrequency = float64(yesCounts[i]) / float64(bin.Count)
+		}
+	}
+	return scores
+}
+
+// printLedgerReport lists the forecasts in the ledger and scores the resolved ones
+func printLedgerReport(ledger *Ledger) {
+	fmt.Printf("\n=== FORECASTS (%s) ===\n", ledger.path)
+	if len(ledger.Forecasts) == 0 {
+		fmt.Printf("No forecasts recorded yet\n")
+		return
+	}
+	for _, forecast := range ledger.Forecasts {
+		status := "unresolved"
+		if resolution, ok := ledger.Resolutions[forecast.ID]; ok {
+			status = "resolved no"
+			if resolution.Outcome {
+				status = "resolved yes"
+			}
+			status += " on " + resolution.Date
+		}
+		fmt.Printf("%4d  %s  %5.1f%%  %-24s  %s\n", forecast.ID, forecast.Date, forecast.Probability*100, status, forecast.Question)
+	}
+
+	scores := scoreForecasts(ledger)
+	fmt.Printf("\n=== CALIBRATION ===\n")
+	fmt.Printf("Resolved forecasts: %d of %d\n", scores.Resolved, len(ledger.Forecasts))
+	if scores.Resolved == 0 {
+		fmt.Printf("Resolve forecasts with: resolve <id> yes|no\n")
+		return
+	}
+	fmt.Printf("Brier score: %.4f (0 is perfect, 0.25 is always saying 50%%)\n", scores.Brier)
+	fmt.Printf("Log score: %.4f (0 is perfect, -0.693 is always saying 50%%)\n", scores.LogScore)
+	fmt.Printf("\nForecast     Count  Mean forecast  Resolved yes\n")
+	for _, bin := range scores.Calibration {
+		if bin.Count == 0 {
+			continue
+		}
+		fmt.Printf("%3.0f-%3.0f%%  %7d  %12.1f%%  %11.1f%%\n", bin.Low*100, bin.High*100, bin.Count, bin.MeanProbability*100, bin.YesFrequency*100)
+	}
+}
+
+// parseOutcome reads a resolution given on the command line
+func parseOutcome(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "yes", "y", "true":
+		return true, nil
+	case "no", "n", "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("outcome %q is not yes or no", s)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLedgerRegisterAndResolve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "forecasts.jsonl")
+	ledger, err := LoadLedger(path)
+	if err != nil {
+		t.Fatalf("LoadLedger returned error for a missing file: %v", err)
+	}
+
+	first, _ := ledger.Register(Forecast{Question: "a?", Date: "2026-01-05", Probability: 0.7, Source: "multi_sim_1/aggregate_results.json"})
+	second, _ := ledger.Register(Forecast{Question: "b?", Date: "2026-01-05", Probability: 0.2, Source: "multi_sim_1/aggregate_results.json"})
+	if first.ID != 1 || second.ID != 2 {
+		t.Errorf("IDs = %d, %d, want 1, 2", first.ID, second.ID)
+	}
+	// Resuming the batch forecasts the same question again
+	again, _ := ledger.Register(Forecast{Question: "a?", Date: "2026-01-06", Probability: 0.75, Source: "multi_sim_1/aggregate_results.json"})
+	if again.ID != 1 {
+		t.Errorf("re-registered forecast got ID %d, want 1", again.ID)
+	}
+	if other, _ := ledger.Register(Forecast{Question: "a?", Date: "2026-01-12", Probability: 0.5, Source: "multi_sim_2/aggregate_results.json"}); other.ID != 3 {
+		t.Errorf("forecast from another run got ID %d, want 3", other.ID)
+	}
+
+	if err := ledger.Resolve(1, false, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	if err := ledger.Resolve(1, true, time.Date(2026, 2, 2, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	if err := ledger.Resolve(9, true, time.Now()); err == nil {
+		t.Errorf("resolved a forecast that doesn't exist")
+	}
+
+	reloaded, err := LoadLedger(path)
+	if err != nil {
+		t.Fatalf("LoadLedger returned error: %v", err)
+	}
+	if len(reloaded.Forecasts) != 3 {
+		t.Fatalf("reloaded %d forecasts, want 3", len(reloaded.Forecasts))
+	}
+	if forecast, _ := reloaded.Forecast(1); forecast.Probability != 0.75 || forecast.Date != "2026-01-06" {
+		t.Errorf("forecast 1 = %+v, want the re-registered one", forecast)
+	}
+	if resolution := reloaded.Resolutions[1]; !resolution.Outcome || resolution.Date != "2026-02-02" {
+		t.Errorf("resolution of forecast 1 = %+v, want the corrected one", resolution)
+	}
+	if _, ok := reloaded.Resolutions[2]; ok {
+		t.Errorf("forecast 2 is resolved")
+	}
+}
+
+func TestScoreForecasts(t *testing.T) {
+	ledger := &Ledger{Resolutions: make(map[int]Resolution)}
+	for i, forecast := range []struct {
+		probability float64
+		outcome     *bool
+	}{
+		{0.8, boolPtr(true)},
+		{0.3, boolPtr(false)},
+		{0.35, boolPtr(true)},
+		{1, boolPtr(false)},
+		{0.5, nil}, // Unresolved
+	} {
+		ledger.Forecasts = append(ledger.Forecasts, Forecast{ID: i + 1, Probability: forecast.probability})
+		if forecast.outcome != nil {
+			ledger.Resolutions[i+1] = Resolution{ID: i + 1, Outcome: *forecast.outcome}
+		}
+	}
+
+	scores := scoreForecasts(ledger)
+	if scores.Resolved != 4 {
+		t.Errorf("resolved = %d, want 4", scores.Resolved)
+	}
+	if want := (0.04 + 0.09 + 0.4225 + 1) / 4; math.Abs(scores.Brier-want) > 1e-9 {
+		t.Errorf("Brier score = %v, want %v", scores.Brier, want)
+	}
+	// The confident miss is scored as if it were 99.9%
+	if want := (math.Log(0.8) + math.Log(0.7) + math.Log(0.35) + math.Log(logScoreFloor)) / 4; math.Abs(scores.LogScore-want) > 1e-9 {
+		t.Errorf("log score = %v, want %v", scores.LogScore, want)
+	}
+
+	wantBins := map[int]CalibrationBin{
+		3: {Count: 2, MeanProbability: 0.325, YesFrequency: 0.5},
+		8: {Count: 1, MeanProbability: 0.8, YesFrequency: 1},
+		9: {Count: 1, MeanProbability: 1, YesFrequency: 0},
+	}
+	for i, bin := range scores.Calibration {
+		want := wantBins[i]
+		if bin.Count != want.Count || math.Abs(bin.MeanProbability-want.MeanProbability) > 1e-9 || bin.YesFrequency != want.YesFrequency {
+			t.Errorf("calibration bin %d = %+v, want %+v", i, bin, want)
+		}
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestFinishedBatchesAreRecorded(t *testing.T) {
+	defer func(saved string) { ledgerPath = saved }(ledgerPath)
+	ledgerPath = filepath.Join(t.TempDir(), "forecasts.jsonl")
+
+	baseDir := filepath.Join(t.TempDir(), "multi_sim_test")
+	scenario := testScenario(1)
+	scenario.Questions = []Question{{Text: "which?", Type: QuestionCategorical, Options: []string{"A", "B"}}}
+	if err := resumeMultipleSimulations(context.Background(), baseDir+"_missing", scenario, 2, NewFakeBackend()); err == nil {
+		t.Fatalf("resumed a directory that doesn't exist")
+	}
+	if err := runSimulationBatch(context.Background(), scenario, 2, NewFakeBackend(), baseDir); err != nil {
+		t.Fatalf("runSimulationBatch returned error: %v", err)
+	}
+	// Finishing the batch records it, and finishing it again replaces the forecast
+	for i := 0; i < 2; i++ {
+		if err := resumeMultipleSimulations(context.Background(), baseDir, scenario, 2, NewFakeBackend()); err != nil {
+			t.Fatalf("resumeMultipleSimulations returned error: %v", err)
+		}
+	}
+
+	ledger, err := LoadLedger(ledgerPath)
+	if err != nil {
+		t.Fatalf("LoadLedger returned error: %v", err)
+	}
+	// Only the yes/no question is a forecast
+	if len(ledger.Forecasts) != 1 {
+		t.Fatalf("ledger has %d forecasts, want 1", len(ledger.Forecasts))
+	}
+	forecast := ledger.Forecasts[0]
+	if forecast.Question != "question?" || forecast.Probability != 1 || forecast.Simulations != 2 || forecast.Source != filepath.Join(baseDir, "aggregate_results.json") {
+		t.Errorf("forecast = %+v", forecast)
+	}
+	if forecast.Date != time.Now().Format(dateLayout) {
+		t.Errorf("forecast dated %s, want today", forecast.Date)
+	}
+}
+
+func TestParseOutcome(t *testing.T) {
+	for input, want := range map[string]bool{"yes": true, "Y": true, "true": true, "no": false, "N": false, "false": false} {
+		if got, err := parseOutcome(input); err != nil || got != want {
+			t.Errorf("parseOutcome(%q) = %v, %v, want %v", input, got, err, want)
+		}
+	}
+	if _, err := parseOutcome("maybe"); err == nil {
+		t.Errorf("parseOutcome accepted %q", "maybe")
+	}
+}
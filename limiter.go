@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// RateLimits configures a RateLimiter. Zero values mean no limit.
+type RateLimits struct {
+	MaxInFlight       int // Requests waiting for a response at the same time
+	RequestsPerMinute int
+	TokensPerMinute   int
+}
+
+// tokenBucket holds up to capacity units and refills at capacity per minute
+type tokenBucket struct {
+	capacity float64
+	level    float64
+	updated  time.Time
+}
+
+func newTokenBucket(perMinute int, now time.Time) *tokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &tokenBucket{capacity: float64(perMinute), level: float64(perMinute), updated: now}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated).Minutes()
+	if elapsed > 0 {
+		b.level += elapsed * b.capacity
+		if b.level > b.capacity {
+			b.level = b.capacity
+		}
+		b.updated = now
+	}
+}
+
+// wait returns how long until n units are available. Requests larger than the
+// bucket only wait for it to fill up, so they can't block forever.
+func (b *tokenBucket) wait(n float64, now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.refill(now)
+	if n > b.capacity {
+		n = b.capacity
+	}
+	if b.level >= n {
+		return 0
+	}
+	return time.Duration((n - b.level) / b.capacity * float64(time.Minute))
+}
+
+// take removes n units. The level can go negative when a request used more
+// than estimated, which delays the following requests.
+func (b *tokenBucket) take(n float64, now time.Time) {
+	if b == nil {
+		return
+	}
+	b.refill(now)
+	b.level -= n
+}
+
+// RateLimiter is shared by every request of every simulation, so a large batch
+// stays within the API's limits instead of relying on retries. A nil
+// *RateLimiter does not limit anything.
+type RateLimiter struct {
+	slots chan struct{}
+
+	mu          sync.Mutex
+	requests    *tokenBucket
+	tokens      *tokenBucket
+	pausedUntil time.Time // Set from Retry-After and exhausted rate-limit headers
+}
+
+func NewRateLimiter(limits RateLimits) *RateLimiter {
+	now := time.Now()
+	limiter := &RateLimiter{
+		requests: newTokenBucket(limits.RequestsPerMinute, now),
+		tokens:   newTokenBucket(limits.TokensPerMinute, now),
+	}
+	if limits.MaxInFlight > 0 {
+		limiter.slots = make(chan struct{}, limits.MaxInFlight)
+	}
+	return limiter
+}
+
+// reserve returns how long to wait before a request of estimatedTokens can be
+// sent, or takes its share of the budget and returns 0
+func (l *RateLimiter) reserve(estimatedTokens int, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+	wait := l.requests.wait(1, now)
+	if tokenWait := l.tokens.wait(float64(estimatedTokens), now); tokenWait > wait {
+		wait = tokenWait
+	}
+	if wait > 0 {
+		return wait
+	}
+	l.requests.take(1, now)
+	l.tokens.take(float64(estimatedTokens), now)
+	return 0
+}
+
+// Acquire blocks until a request of about estimatedTokens tokens may be sent,
+// or ctx is cancelled. The returned function must be called with the tokens
+// the request actually used (0 if unknown) once it finishes.
+func (l *RateLimiter) Acquire(ctx context.Context, estimatedTokens int) (func(usedTokens int), error) {
+	if l == nil {
+		return func(int) {}, ctx.Err()
+	}
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	for {
+		wait := l.reserve(estimatedTokens, time.Now())
+		if wait <= 0 {
+			break
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			if l.slots != nil {
+				<-l.slots
+			}
+			return nil, ctx.Err()
+		}
+	}
+
+	var once sync.Once
+	return func(usedTokens int) {
+		once.Do(func() {
+			if usedTokens > 0 {
+				l.mu.Lock()
+				l.tokens.take(float64(usedTokens-estimatedTokens), time.Now())
+				l.mu.Unlock()
+			}
+			if l.slots != nil {
+				<-l.slots
+			}
+		})
+	}, nil
+}
+
+// PauseUntil holds back every request until t
+func (l *RateLimiter) PauseUntil(t time.Time) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if t.After(l.pausedUntil) {
+		if verbose {
+			log.Printf("[RATELIMIT] Pausing requests for %v", time.Until(t).Round(time.Millisecond))
+		}
+		l.pausedUntil = t
+	}
+}
+
+// Observe pauses requests when a response says the requests or tokens left in
+// the current window have run out, until that window resets
+func (l *RateLimiter) Observe(headers openai.RateLimitHeaders) {
+	if headers.LimitRequests > 0 && headers.RemainingRequests == 0 && headers.ResetRequests != "" {
+		l.PauseUntil(headers.ResetRequests.Time())
+	}
+	if headers.LimitTokens > 0 && headers.RemainingTokens == 0 && headers.ResetTokens != "" {
+		l.PauseUntil(headers.ResetTokens.Time())
+	}
+}
+
+// retryAfter parses a Retry-After header, given either in seconds or as an
+// HTTP date. It returns false if the header is missing or malformed.
+func retryAfter(header http.Header, now time.Time) (time.Time, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return time.Time{}, false
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		return now.Add(time.Duration(seconds * float64(time.Second))), true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// estimateTokens guesses the tokens a request will use before sending it:
+// about four characters per prompt token, plus an allowance for the reply
+func estimateTokens(prompt string) int {
+	return len(prompt)/4 + 500
+}
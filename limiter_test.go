@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	bucket := newTokenBucket(60, start)
+
+	if wait := bucket.wait(60, start); wait != 0 {
+		t.Fatalf("full bucket wait = %v, want 0", wait)
+	}
+	bucket.take(60, start)
+	if wait := bucket.wait(1, start); wait != time.Second {
+		t.Errorf("empty bucket wait = %v, want 1s", wait)
+	}
+	if wait := bucket.wait(1, start.Add(time.Second)); wait != 0 {
+		t.Errorf("wait after refilling = %v, want 0", wait)
+	}
+	// Requests larger than the bucket only wait for it to fill up
+	if wait := bucket.wait(1000, start.Add(time.Second)); wait != 59*time.Second {
+		t.Errorf("oversized request wait = %v, want 59s", wait)
+	}
+	// Refilling stops at capacity
+	if wait := bucket.wait(60, start.Add(time.Hour)); wait != 0 {
+		t.Errorf("wait after an hour = %v, want 0", wait)
+	}
+	bucket.take(90, start.Add(time.Hour))
+	if wait := bucket.wait(1, start.Add(time.Hour)); wait != 31*time.Second {
+		t.Errorf("wait after overspending = %v, want 31s", wait)
+	}
+
+	var unlimited *tokenBucket = newTokenBucket(0, start)
+	if wait := unlimited.wait(1e9, start); wait != 0 {
+		t.Errorf("unlimited bucket wait = %v, want 0", wait)
+	}
+}
+
+func TestRateLimiterReserve(t *testing.T) {
+	limiter := NewRateLimiter(RateLimits{RequestsPerMinute: 2, TokensPerMinute: 1000})
+	now := time.Now()
+
+	if wait := limiter.reserve(400, now); wait != 0 {
+		t.Fatalf("first request wait = %v, want 0", wait)
+	}
+	// Out of tokens before running out of requests
+	if wait := limiter.reserve(700, now); wait <= 0 {
+		t.Errorf("second request was not held back by the token limit")
+	}
+	if wait := limiter.reserve(500, now); wait != 0 {
+		t.Errorf("request within both limits wait = %v, want 0", wait)
+	}
+	if wait := limiter.reserve(1, now); wait <= 0 {
+		t.Errorf("third request was not held back by the request limit")
+	}
+
+	limiter = NewRateLimiter(RateLimits{})
+	limiter.PauseUntil(now.Add(time.Minute))
+	if wait := limiter.reserve(1, now); wait != time.Minute {
+		t.Errorf("paused limiter wait = %v, want 1m", wait)
+	}
+}
+
+func TestRateLimiterMaxInFlight(t *testing.T) {
+	limiter := NewRateLimiter(RateLimits{MaxInFlight: 3})
+
+	var mu sync.Mutex
+	inFlight, maxSeen := 0, 0
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := limiter.Acquire(context.Background(), 10)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			inFlight++
+			if inFlight > maxSeen {
+				maxSeen = inFlight
+			}
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			release(0)
+		}()
+	}
+	wg.Wait()
+	if maxSeen != 3 {
+		t.Errorf("at most %d requests were in flight, want 3", maxSeen)
+	}
+
+	// A nil limiter lets everything through
+	var unlimited *RateLimiter
+	release, err := unlimited.Acquire(context.Background(), 1e9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release(0)
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value  string
+		want   time.Time
+		wantOK bool
+	}{
+		{"", time.Time{}, false},
+		{"3", now.Add(3 * time.Second), true},
+		{"0.5", now.Add(500 * time.Millisecond), true},
+		{"Thu, 01 Jan 2026 00:01:00 GMT", now.Add(time.Minute), true},
+		{"soon", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.value != "" {
+			header.Set("Retry-After", tt.value)
+		}
+		got, ok := retryAfter(header, now)
+		if ok != tt.wantOK || !got.Equal(tt.want) {
+			t.Errorf("retryAfter(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestOpenAIRequestsFollowRateLimitHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+		body    string
+		minWait time.Duration
+	}{
+		{
+			name:    "Retry-After on 429",
+			headers: map[string]string{"Retry-After": "20"},
+			status:  http.StatusTooManyRequests,
+			body:    `{"error": {"message": "Rate limit reached", "type": "requests"}}`,
+			minWait: 19 * time.Second,
+		},
+		{
+			name: "no requests left in the window",
+			headers: map[string]string{
+				"x-ratelimit-limit-requests":     "100",
+				"x-ratelimit-remaining-requests": "0",
+				"x-ratelimit-reset-requests":     "30s",
+			},
+			status:  http.StatusOK,
+			body:    `{"choices": [{"message": {"role": "assistant", "content": "{}"}}]}`,
+			minWait: 29 * time.Second,
+		},
+		{
+			name:    "requests left",
+			headers: map[string]string{"x-ratelimit-limit-requests": "100", "x-ratelimit-remaining-requests": "99"},
+			status:  http.StatusOK,
+			body:    `{"choices": [{"message": {"role": "assistant", "content": "{}"}}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for key, value := range tt.headers {
+					w.Header().Set(key, value)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			limiter := NewRateLimiter(RateLimits{})
+			backend := NewOpenAIBackend("test", server.URL, limiter)
+			createChatCompletion(context.Background(), OpenAIRequest{prompt: "hi", model: "test", client: backend.client, limiter: limiter}, openai.ChatCompletionRequest{
+				Model:    "test",
+				Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}},
+			})
+
+			wait := limiter.reserve(1, time.Now())
+			if tt.minWait == 0 && wait != 0 {
+				t.Errorf("limiter paused for %v, want no pause", wait)
+			}
+			if wait < tt.minWait {
+				t.Errorf("limiter paused for %v, want at least %v", wait, tt.minWait)
+			}
+		})
+	}
+}
+
+func TestCancellingStopsRetries(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": {"message": "server error", "type": "server_error"}}`))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	backend := NewOpenAIBackend("test", server.URL, nil)
+	start := time.Now()
+	_, _, err := backend.FetchJSON(ctx, LLMRequest{Prompt: "question", Model: GPT5_2})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want the context's error", err)
+	}
+	// Without cancellation the backoff alone would take 15s
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("FetchJSON took %v after being cancelled", elapsed)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 1 {
+		t.Errorf("%d attempts, want 1", attempts)
+	}
+}
+
+func TestCancellingStopsWaitingForTheLimiter(t *testing.T) {
+	limiter := NewRateLimiter(RateLimits{MaxInFlight: 1})
+	release, err := limiter.Acquire(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.Acquire(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want the context's error", err)
+	}
+
+	limiter = NewRateLimiter(RateLimits{})
+	limiter.PauseUntil(time.Now().Add(time.Hour))
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.Acquire(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want the context's error", err)
+	}
+}
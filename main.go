@@ -1,17 +1,23 @@
 package main
 
 import (
+	"context"
 	"log"
+	"math"
 	"github.com/joho/godotenv"
 	"os"
+	"os/signal"
 	"fmt"
 	"encoding/json"
 	"sync"
+	"syscall"
 	"flag"
 	"bufio"
 	"strings"
 	"path/filepath"
 	"io/ioutil"
+	"sort"
+	"strconv"
 	"time"
 	openai "github.com/sashabaranov/go-openai"
 	jsonschema "github.com/sashabaranov/go-openai/jsonschema"
@@ -20,6 +26,12 @@ import (
 var verbose bool
 var multiline bool
 
+// Number of failed simulations a batch tolerates before aborting; -1 for no limit
+var maxFailures int = -1
+
+// Cap on simulations in adaptive mode; 0 turns adaptive mode off
+var maxSimulations int = 0
+
 // Logger interface for simulation logging
 type SimLogger interface {
 	Printf(format string, v ...interface{})
@@ -61,6 +73,7 @@ type Actor struct {
 	Name string `json:"name"`
 	Goals string `json:"goals"`
 	Powers string `json:"powers"`
+	Cadence int `json:"cadence"` // Decides every Cadence turns, starting with the first; 0 or 1 for every turn
 }
 type Actors struct {
 	Actors []Actor
@@ -70,34 +83,44 @@ type Actors struct {
 type WorldState struct {
 	Events []string `json:"events"`
 	Description string `json:"description"`
+	Date string `json:"date"` // Simulated date, YYYY-MM-DD; set by the simulation, empty if the scenario has no calendar
+	Variables []VariableValue `json:"variables"` // Values of the scenario's variables, in declaration order
 }
 
 type ActorView struct {
 	VisibleEvents []string `json:"visible_events"`
 	Interpretation string `json:"interpretation"`
+	Variables []VariableValue `json:"variables"` // Values of the variables the actor knows, as they are in the world state
 }
 
 type ActorAction struct {
 	ActorName string `json:"actor_name"`
 	Action string `json:"action"`
 	Reasoning string `json:"reasoning"`
+	Wait bool `json:"wait"` // Deliberately does nothing this turn; Action says what they wait for
+	Plan string `json:"plan"` // What they intend to do next, and commitments made; kept in their memory
+	Messages []Message `json:"messages"` // Private messages to other actors, read on their next decision
 }
 
 type SummarizationAnswer struct {
 	Answer string `json:"answer"`
 	YesNo bool `json:"yes_no"`
+	Unresolved bool `json:"unresolved"` // The final world state doesn't settle the question
+	Probability *float64 `json:"probability"` // That the answer is yes; nil if the server ignored the schema
 }
 
-func GetActors(situation_description string, client *openai.Client) (Actors, error){
+func GetActors(ctx context.Context, situation_description string, backend LLMBackend) (Actors, error){
 	prompt := `Provide a list of the relevant actors and their goals as a JSON object \
 	{
 		actors: [
-		{"name": "Name 1", "goals": "Description of goals", "powers": "Formal and informal powers"}
-		{"name": "Name 2", "goals": "Description of goals 2", "powers": "Formal and informal powers"},
+		{"name": "Name 1", "goals": "Description of goals", "powers": "Formal and informal powers", "cadence": 1}
+		{"name": "Name 2", "goals": "Description of goals 2", "powers": "Formal and informal powers", "cadence": 2},
 	  	...
 		],
 		observations: "any notes"
 	}
+	where cadence is how often, in turns, the actor realistically takes decisions: 1 for every turn, or e.g. 2 for \
+	an actor that only decides at meetings every other turn
 	for the following situation: ` + situation_description
 
 	var actors Actors
@@ -117,9 +140,9 @@ func GetActors(situation_description string, client *openai.Client) (Actors, err
 		Strict: true,
 	}
 	if verbose {
-		log.Printf("[GetActors] Making OpenAI API call with model: %s", GPT5_2)
+		log.Printf("[GetActors] Making LLM call with model: %s", models.Actors)
 	}
-	openai_json, err := fetchOpenAIAnswerJSON(OpenAIRequest{prompt: prompt, model: GPT5_2, client: client}, openai_schema, verbose)
+	openai_json, _, err := backend.FetchJSON(ctx, LLMRequest{Prompt: prompt, Model: models.Actors, Schema: openai_schema, Stage: StageActors})
 	if err != nil {
 		if verbose {
 			log.Printf("[GetActors] OpenAI API call failed: %v", err)
@@ -146,7 +169,7 @@ func GetActors(situation_description string, client *openai.Client) (Actors, err
 }
 
 // AdjustActors takes existing actors and adjusts them based on external information
-func AdjustActors(actors Actors, external_info string, client *openai.Client) (Actors, error) {
+func AdjustActors(ctx context.Context, actors Actors, external_info string, backend LLMBackend) (Actors, error) {
 	if verbose {
 		log.Printf("[AdjustActors] Adjusting actors based on external information")
 	}
@@ -160,7 +183,7 @@ func AdjustActors(actors Actors, external_info string, client *openai.Client) (A
 
 And this new external information: %s
 
-Please adjust the actors (their goals, powers, or add/remove actors) based on this new information. Return the adjusted list in the same JSON format.`, string(actorsJSON), external_info)
+Please adjust the actors (their goals, powers, cadence, or add/remove actors) based on this new information. Return the adjusted list in the same JSON format.`, string(actorsJSON), external_info)
 
 	var adjustedActors Actors
 	schema, err := jsonschema.GenerateSchemaForType(adjustedActors)
@@ -174,7 +197,7 @@ Please adjust the actors (their goals, powers, or add/remove actors) based on th
 		Strict: true,
 	}
 
-	openai_json, err := fetchOpenAIAnswerJSON(OpenAIRequest{prompt: prompt, model: GPT5_2, client: client}, openai_schema, verbose)
+	openai_json, _, err := backend.FetchJSON(ctx, LLMRequest{Prompt: prompt, Model: models.Actors, Schema: openai_schema, Stage: StageActors})
 	if err != nil {
 		return Actors{}, err
 	}
@@ -191,7 +214,7 @@ Please adjust the actors (their goals, powers, or add/remove actors) based on th
 }
 
 // SummarizeWorldState creates a comprehensive summary of the current state of the world
-func SummarizeWorldState(situation_description string, actors Actors, client *openai.Client) (WorldState, error) {
+func SummarizeWorldState(ctx context.Context, situation_description string, actors Actors, backend LLMBackend) (WorldState, error) {
 	if verbose {
 		log.Printf("[SummarizeWorldState] Creating world state summary")
 	}
@@ -223,7 +246,7 @@ Format: {"events": ["event 1", "event 2", ...], "description": "overall descript
 		Strict: true,
 	}
 
-	openai_json, err := fetchOpenAIAnswerJSON(OpenAIRequest{prompt: prompt, model: GPT5_2, client: client}, openai_schema, verbose)
+	openai_json, _, err := backend.FetchJSON(ctx, LLMRequest{Prompt: prompt, Model: models.InitialState, Schema: openai_schema, Stage: StageInitialState})
 	if err != nil {
 		return WorldState{}, err
 	}
@@ -241,7 +264,7 @@ Format: {"events": ["event 1", "event 2", ...], "description": "overall descript
 
 // FilterWorldStateForActor takes the world state and an actor, and returns only the information
 // that the actor would realistically know based on their position and powers
-func FilterWorldStateForActor(worldState WorldState, actor Actor, client *openai.Client) (ActorView, error) {
+func FilterWorldStateForActor(ctx context.Context, worldState WorldState, actor Actor, backend LLMBackend) (ActorView, error) {
 	if verbose {
 		log.Printf("[FilterWorldStateForActor] Filtering world state for actor: %s", actor.Name)
 	}
@@ -256,15 +279,22 @@ func FilterWorldStateForActor(worldState WorldState, actor Actor, client *openai
 		return ActorView{}, fmt.Errorf("failed to marshal actor: %v", err)
 	}
 
+	variablesField := ""
+	if len(worldState.Variables) > 0 {
+		variablesField = "\n- variables: the variables of the world state whose value the actor would know, copied as they are"
+	}
 	prompt := fmt.Sprintf(`Given this complete world state: %s
 
 And this actor: %s
 
 Determine what information this actor would realistically know, see, or have access to based on their position and powers. Return a JSON object with:
 - visible_events: array of events/information the actor would know about
-- interpretation: how the actor interprets and understands the visible information given their goals
+- interpretation: how the actor interprets and understands the visible information given their goals%s
 
-Only include information the actor would actually have access to. Some events might be completely unknown to them.`, string(worldStateJSON), string(actorJSON))
+Only include information the actor would actually have access to. Some events might be completely unknown to them.`, string(worldStateJSON), string(actorJSON), variablesField)
+	if worldState.Date != "" {
+		prompt += fmt.Sprintf("\n\nThe current simulated date is %s.", worldState.Date)
+	}
 
 	var actorView ActorView
 	schema, err := jsonschema.GenerateSchemaForType(actorView)
@@ -278,7 +308,7 @@ Only include information the actor would actually have access to. Some events mi
 		Strict: true,
 	}
 
-	openai_json, err := fetchOpenAIAnswerJSON(OpenAIRequest{prompt: prompt, model: GPT5_2, client: client}, openai_schema, verbose)
+	openai_json, _, err := backend.FetchJSON(ctx, LLMRequest{Prompt: prompt, Model: models.Filter, Schema: openai_schema, Stage: StageFilter, Actor: actor.Name})
 	if err != nil {
 		return ActorView{}, err
 	}
@@ -287,6 +317,8 @@ Only include information the actor would actually have access to. Some events mi
 	if err != nil {
 		return ActorView{}, err
 	}
+	// The model picks which variables the actor knows, not their values
+	actorView.Variables = knownValues(actorView.Variables, worldState.Variables)
 
 	if verbose {
 		log.Printf("[FilterWorldStateForActor] World state filtered successfully for %s", actor.Name)
@@ -294,8 +326,10 @@ Only include information the actor would actually have access to. Some events mi
 	return actorView, nil
 }
 
-// ActorTakesAction has the actor decide what action to take based on their view of the world
-func ActorTakesAction(actor Actor, actorView ActorView, client *openai.Client, logger SimLogger) (ActorAction, error) {
+// ActorTakesAction has the actor decide what action to take based on their view of the world,
+// their memory of earlier turns and the messages in their inbox. contacts are the other actors,
+// whom they can message.
+func ActorTakesAction(ctx context.Context, actor Actor, actorView ActorView, turn Turn, memory string, inbox []Message, contacts []string, backend LLMBackend, logger SimLogger) (ActorAction, error) {
 	if verbose {
 		log.Printf("[ActorTakesAction] Getting action for actor: %s", actor.Name)
 	}
@@ -317,7 +351,29 @@ And their view of the world: %s
 What action would this actor take given their goals, powers, and what they know? Return a JSON object with:
 - actor_name: the name of the actor
 - action: a description of the action they take
-- reasoning: why they are taking this action given their goals and what they know`, string(actorJSON), string(actorViewJSON))
+- reasoning: why they are taking this action given their goals and what they know
+- wait: true if the actor deliberately does nothing this turn, e.g. to wait for more information or for a scheduled decision, in which case action says what they wait for. Doing nothing is often the realistic choice; only act if the actor would actually move now
+- plan: what the actor intends to do in the coming turns, including any commitments they have made`, string(actorJSON), string(actorViewJSON))
+	if len(contacts) > 0 {
+		prompt += fmt.Sprintf(`
+- messages: private messages the actor sends, e.g. to negotiate, threaten or coordinate, each with from (the actor's name), to (a list of recipients, or ["%s"] for all of them) and text. Only the recipients read a message, when they next decide, and it does not become public. Leave the list empty to send none. The actor can message: %s`, broadcastRecipient, strings.Join(contacts, ", "))
+	}
+	if memory != "" {
+		prompt += fmt.Sprintf("\n\nThe actor's memory of the earlier turns they decided on, including their plans and commitments. A real actor stays consistent with these unless circumstances have changed:\n%s", memory)
+	}
+	if actor.Cadence > 1 {
+		prompt += fmt.Sprintf("\n\nThe actor only takes decisions every %d turns, so this decision stands until turn %d.", actor.Cadence, turn.Number+actor.Cadence)
+	}
+	if len(inbox) > 0 {
+		inboxJSON, err := json.Marshal(inbox)
+		if err != nil {
+			return ActorAction{}, fmt.Errorf("failed to marshal messages: %v", err)
+		}
+		prompt += fmt.Sprintf("\n\nPrivate messages the actor received since their last decision: %s", string(inboxJSON))
+	}
+	if turn.Date != "" {
+		prompt += fmt.Sprintf("\n\nThe current simulated date is %s. The action plays out over this turn, which ends on %s.", turn.Date, turn.EndDate)
+	}
 
 	var actorAction ActorAction
 	schema, err := jsonschema.GenerateSchemaForType(actorAction)
@@ -331,7 +387,7 @@ What action would this actor take given their goals, powers, and what they know?
 		Strict: true,
 	}
 
-	openai_json, err := fetchOpenAIAnswerJSON(OpenAIRequest{prompt: prompt, model: GPT5_2, client: client}, openai_schema, verbose)
+	openai_json, _, err := backend.FetchJSON(ctx, LLMRequest{Prompt: prompt, Model: models.Action, Schema: openai_schema, Stage: StageAction, Actor: actor.Name})
 	if err != nil {
 		return ActorAction{}, err
 	}
@@ -340,6 +396,9 @@ What action would this actor take given their goals, powers, and what they know?
 	if err != nil {
 		return ActorAction{}, err
 	}
+	for i := range actorAction.Messages {
+		actorAction.Messages[i].From = actor.Name
+	}
 
 	if verbose {
 		log.Printf("[ActorTakesAction] Action determined for %s", actor.Name)
@@ -347,37 +406,77 @@ What action would this actor take given their goals, powers, and what they know?
 
 	// Print the action taken to the logger
 	if logger != nil {
-		logger.Printf("\n%s takes action: %s\n", actorAction.ActorName, actorAction.Action)
+		if actorAction.Wait {
+			logger.Printf("\n%s waits: %s\n", actorAction.ActorName, actorAction.Action)
+		} else {
+			logger.Printf("\n%s takes action: %s\n", actorAction.ActorName, actorAction.Action)
+		}
 		logger.Printf("Reasoning: %s\n", actorAction.Reasoning)
+		for _, message := range actorAction.Messages {
+			logger.Printf("Message to %s: %s\n", strings.Join(message.To, ", "), message.Text)
+		}
 	}
 
 	return actorAction, nil
 }
 
-// RunSimulationTurn runs one turn of the simulation where each actor observes and acts
-func RunSimulationTurn(worldState WorldState, actors Actors, client *openai.Client, logger SimLogger) ([]ActorAction, WorldState, error) {
+// actsOnTurn reports whether the actor takes a decision on turn n, from 1
+func (a Actor) actsOnTurn(n int) bool {
+	return a.Cadence <= 1 || (n-1)%a.Cadence == 0
+}
+
+// scheduledWait is the action of an actor that is not due to decide this
+// turn. It is recorded without asking the model.
+func scheduledWait(actor Actor, turn Turn) ActorAction {
+	next := turn.Number + actor.Cadence - (turn.Number-1)%actor.Cadence
+	return ActorAction{
+		ActorName: actor.Name,
+		Action:    "Takes no new decision; earlier decisions stand",
+		Reasoning: fmt.Sprintf("%s only decides every %d turns; the next decision is on turn %d", actor.Name, actor.Cadence, next),
+		Wait:      true,
+	}
+}
+
+// RunSimulationTurn runs one turn of the simulation where each actor observes and acts.
+// Actors that are not due to decide on this turn, per their cadence, wait without a call to the model.
+// history holds the actions of the earlier turns, whose messages are delivered to their recipients.
+// The turn's negotiations are held after the actors observe and before they act.
+// Actors decide with their memories; if memories is not nil, what each actor saw and did is
+// recorded in it.
+func RunSimulationTurn(ctx context.Context, turn Turn, worldState WorldState, actors Actors, history [][]ActorAction, memories Memories, backend LLMBackend, logger SimLogger) ([]ActorAction, []NegotiationOutcome, WorldState, error) {
 	if verbose {
 		log.Printf("[RunSimulationTurn] Starting simulation turn with %d actors", len(actors.Actors))
 	}
 
 	// Process each actor in parallel
 	type actorResult struct {
+		view   ActorView
 		action ActorAction
 		err    error
 		index  int
 	}
 
+	// Stop the other actors as soon as one fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Each actor that is due to decide observes in parallel
+	views := make(map[string]ActorView)
 	results := make(chan actorResult, len(actors.Actors))
 	var wg sync.WaitGroup
-
-	// Each actor observes and acts in parallel
 	for i, actor := range actors.Actors {
+		if !actor.actsOnTurn(turn.Number) {
+			if logger != nil {
+				logger.Printf("\n%s is not due to decide this turn\n", actor.Name)
+			}
+			continue
+		}
 		wg.Add(1)
 		go func(idx int, act Actor) {
 			defer wg.Done()
 
 			// Filter world state for this actor
-			actorView, err := FilterWorldStateForActor(worldState, act, client)
+			actorView, err := FilterWorldStateForActor(ctx, worldState, act, backend)
 			if err != nil {
 				results <- actorResult{
 					err:   fmt.Errorf("failed to filter world state for %s: %v", act.Name, err),
@@ -385,9 +484,74 @@ func RunSimulationTurn(worldState WorldState, actors Actors, client *openai.Clie
 				}
 				return
 			}
+			results <- actorResult{view: actorView, index: idx}
+		}(i, actor)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	for result := range results {
+		if result.err != nil {
+			return nil, nil, worldState, result.err
+		}
+		views[actors.Actors[result.index].Name] = result.view
+	}
 
-			// Actor takes action based on their view
-			action, err := ActorTakesAction(act, actorView, client, logger)
+	// Negotiations, one after the other. The parties see how they ended.
+	var negotiations []NegotiationOutcome
+	for _, negotiation := range turn.Negotiations {
+		parties, err := findParties(negotiation, actors)
+		if err != nil {
+			if logger != nil {
+				logger.Printf("\nSkipping negotiation %q: %v\n", negotiation.Topic, err)
+			}
+			continue
+		}
+		due := true
+		for _, party := range parties {
+			due = due && party.actsOnTurn(turn.Number)
+		}
+		if !due {
+			if logger != nil {
+				logger.Printf("\nSkipping negotiation %q: not every party decides this turn\n", negotiation.Topic)
+			}
+			continue
+		}
+
+		outcome, err := Negotiate(ctx, negotiation, parties, views, memories, turn, backend, logger)
+		if err != nil {
+			return nil, nil, worldState, err
+		}
+		negotiations = append(negotiations, outcome)
+		if logger != nil {
+			logger.Printf("\n%s\n", outcome.Event())
+		}
+		for _, party := range parties {
+			view := views[party.Name]
+			view.VisibleEvents = append(append([]string(nil), view.VisibleEvents...), outcome.Event())
+			views[party.Name] = view
+		}
+	}
+
+	// Each actor acts in parallel, based on their view and messages
+	results = make(chan actorResult, len(actors.Actors))
+	for i, actor := range actors.Actors {
+		if !actor.actsOnTurn(turn.Number) {
+			results <- actorResult{action: scheduledWait(actor, turn), index: i}
+			continue
+		}
+		wg.Add(1)
+		go func(idx int, act Actor) {
+			defer wg.Done()
+
+			var contacts []string
+			for _, other := range actors.Actors {
+				if other.Name != act.Name {
+					contacts = append(contacts, other.Name)
+				}
+			}
+			action, err := ActorTakesAction(ctx, act, views[act.Name], turn, memories.digest(act.Name), inbox(act, history), contacts, backend, logger)
 			if err != nil {
 				results <- actorResult{
 					err:   fmt.Errorf("failed to get action for %s: %v", act.Name, err),
@@ -413,25 +577,49 @@ func RunSimulationTurn(worldState WorldState, actors Actors, client *openai.Clie
 	actions := make([]ActorAction, len(actors.Actors))
 	for result := range results {
 		if result.err != nil {
-			return nil, worldState, result.err
+			return nil, nil, worldState, result.err
 		}
 		actions[result.index] = result.action
 	}
 
-	// Update world state based on actions
-	updatedWorldState, err := UpdateWorldState(worldState, actions, client)
+	// Update world state based on actions and negotiations
+	if memories != nil {
+		for i, actor := range actors.Actors {
+			view, observed := views[actor.Name]
+			if !observed {
+				continue
+			}
+			memories.record(actor.Name, MemoryEntry{
+				Turn:           turn.Number,
+				Date:           turn.Date,
+				Observed:       view.VisibleEvents,
+				Interpretation: view.Interpretation,
+				Action:         actions[i].Action,
+				Waited:         actions[i].Wait,
+				Reasoning:      actions[i].Reasoning,
+				Plan:           actions[i].Plan,
+				Received:       inbox(actor, history),
+				Sent:           actions[i].Messages,
+			})
+		}
+	}
+
+	updatedWorldState, err := UpdateWorldState(ctx, worldState, actions, negotiations, turn, backend)
 	if err != nil {
-		return actions, worldState, fmt.Errorf("failed to update world state: %v", err)
+		return actions, negotiations, worldState, fmt.Errorf("failed to update world state: %v", err)
 	}
 
 	if verbose {
 		log.Printf("[RunSimulationTurn] Simulation turn completed")
 	}
-	return actions, updatedWorldState, nil
+	return actions, negotiations, updatedWorldState, nil
 }
 
-// UpdateWorldState updates the world state based on the actions taken by actors
-func UpdateWorldState(worldState WorldState, actions []ActorAction, client *openai.Client) (WorldState, error) {
+// UpdateWorldState updates the world state based on the actions taken by
+// actors during turn, and the negotiations held in it, whose outcomes become
+// events. The updated world state is dated at the end of the turn, and has a
+// value for every variable of the turn.
+func UpdateWorldState(ctx context.Context, worldState WorldState, actions []ActorAction, negotiations []NegotiationOutcome, turn Turn, backend LLMBackend) (WorldState, error) {
 	if verbose {
 		log.Printf("[UpdateWorldState] Updating world state based on %d actions", len(actions))
 	}
@@ -441,7 +629,8 @@ func UpdateWorldState(worldState WorldState, actions []ActorAction, client *open
 		return WorldState{}, fmt.Errorf("failed to marshal world state: %v", err)
 	}
 
-	actionsJSON, err := json.Marshal(actions)
+	// Messages are private, and the world state is what everyone's view comes from
+	actionsJSON, err := json.Marshal(withoutMessages(actions))
 	if err != nil {
 		return WorldState{}, fmt.Errorf("failed to marshal actions: %v", err)
 	}
@@ -450,9 +639,49 @@ func UpdateWorldState(worldState WorldState, actions []ActorAction, client *open
 
 And these actions taken by actors: %s
 
+Actions with wait set to true are decisions to do nothing for now; they change nothing by themselves.
+
 Update the world state to reflect the consequences of these actions. Return the updated world state in the same JSON format with:
 - events: updated array of events including the consequences of the actions
 - description: updated description of the overall state`, string(worldStateJSON), string(actionsJSON))
+	// The JSON fields first, then what the model should take into account
+	if turn.Date != "" {
+		prompt += fmt.Sprintf(`
+- date: %s`, turn.EndDate)
+	}
+	if len(turn.Variables) > 0 {
+		prompt += fmt.Sprintf(`
+- variables: the value of every one of these variables at the end of the turn, each with a justification saying what in the actions or events moved it, or why it stayed the same:
+%s`, describeVariables(turn.Variables))
+	}
+	if turn.Date != "" {
+		prompt += fmt.Sprintf(`
+
+The actions were taken between %s and %s. Describe the world as of %s, and start each new event with the simulated date on which it happened, as [YYYY-MM-DD].`, turn.Date, turn.EndDate, turn.EndDate)
+	}
+	if len(turn.Variables) > 0 {
+		prompt += `
+
+Change a variable only for a reason you can point to, and keep it within its bounds.`
+	}
+	var negotiationEvents []string
+	for _, outcome := range negotiations {
+		event := outcome.Event()
+		if turn.Date != "" {
+			event = fmt.Sprintf("[%s] %s", turn.Date, event)
+		}
+		negotiationEvents = append(negotiationEvents, event)
+	}
+	if len(negotiationEvents) > 0 {
+		negotiationsJSON, err := json.Marshal(negotiationEvents)
+		if err != nil {
+			return WorldState{}, fmt.Errorf("failed to marshal negotiations: %v", err)
+		}
+		prompt += fmt.Sprintf(`
+
+These negotiations were concluded before the actors acted: %s
+They are added to the events as they are, so don't repeat them, but do reflect their consequences.`, string(negotiationsJSON))
+	}
 
 	var updatedWorldState WorldState
 	schema, err := jsonschema.GenerateSchemaForType(updatedWorldState)
@@ -466,7 +695,7 @@ Update the world state to reflect the consequences of these actions. Return the
 		Strict: true,
 	}
 
-	openai_json, err := fetchOpenAIAnswerJSON(OpenAIRequest{prompt: prompt, model: GPT5_2, client: client}, openai_schema, verbose)
+	openai_json, _, err := backend.FetchJSON(ctx, LLMRequest{Prompt: prompt, Model: models.Update, Schema: openai_schema, Stage: StageUpdate})
 	if err != nil {
 		return WorldState{}, err
 	}
@@ -475,6 +704,10 @@ Update the world state to reflect the consequences of these actions. Return the
 	if err != nil {
 		return WorldState{}, err
 	}
+	// The calendar, not the model, decides the date
+	updatedWorldState.Date = turn.EndDate
+	updatedWorldState.Variables = reconcileVariables(turn.Variables, worldState.Variables, updatedWorldState.Variables)
+	updatedWorldState.Events = append(updatedWorldState.Events, negotiationEvents...)
 
 	if verbose {
 		log.Printf("[UpdateWorldState] World state updated successfully")
@@ -482,36 +715,29 @@ Update the world state to reflect the consequences of these actions. Return the
 	return updatedWorldState, nil
 }
 
-// AnswerSummarizationQuestion answers a specific question about the final state of the simulation
-func AnswerSummarizationQuestion(question string, worldState WorldState, allActions [][]ActorAction, client *openai.Client) (string, bool, error) {
+// AnswerSummarizationQuestion answers a specific question about the final state of the simulation,
+// with a probability, and says whether the simulation settled it at all
+func AnswerSummarizationQuestion(ctx context.Context, question Question, worldState WorldState, allActions [][]ActorAction, backend LLMBackend) (SummarizationAnswer, error) {
 	if verbose {
-		log.Printf("[AnswerSummarizationQuestion] Answering question: %s", question)
+		log.Printf("[AnswerSummarizationQuestion] Answering question: %s", question.Text)
 	}
 
-	worldStateJSON, err := json.Marshal(worldState)
+	prompt, err := answerPrompt(question, worldState, allActions)
 	if err != nil {
-		return "", false, fmt.Errorf("failed to marshal world state: %v", err)
+		return SummarizationAnswer{}, err
 	}
-
-	allActionsJSON, err := json.Marshal(allActions)
-	if err != nil {
-		return "", false, fmt.Errorf("failed to marshal all actions: %v", err)
-	}
-
-	prompt := fmt.Sprintf(`Given this final world state: %s
-
-And this history of all actions taken across turns: %s
-
-Please answer this question: %s
+	prompt += `
 
 Provide a JSON response with:
 - answer: a clear detailed answer, referencing specific events and actions from the simulation
-- yes_no: a boolean (true/false) indicating the yes/no answer to the question`, string(worldStateJSON), string(allActionsJSON), question)
+- yes_no: a boolean (true/false) indicating the yes/no answer to the question, or your best guess if it is unresolved
+- unresolved: true if the final world state does not settle the question, e.g. because the decision has not been taken yet or there is not enough information, otherwise false
+- probability: your probability, between 0 and 1, that the answer is yes. Use values close to 0 or 1 only when the simulation settles the question`
 
 	var summarizationAnswer SummarizationAnswer
 	schema, err := jsonschema.GenerateSchemaForType(summarizationAnswer)
 	if err != nil {
-		return "", false, fmt.Errorf("schema generation failed: %v", err)
+		return SummarizationAnswer{}, fmt.Errorf("schema generation failed: %v", err)
 	}
 
 	openai_schema := openai.ChatCompletionResponseFormatJSONSchema{
@@ -520,67 +746,130 @@ Provide a JSON response with:
 		Strict: true,
 	}
 
-	openai_json, err := fetchOpenAIAnswerJSON(OpenAIRequest{prompt: prompt, model: GPT5_2, client: client}, openai_schema, verbose)
+	openai_json, _, err := backend.FetchJSON(ctx, LLMRequest{Prompt: prompt, Model: models.Answer, Schema: openai_schema, Stage: StageAnswer})
 	if err != nil {
-		return "", false, err
+		return SummarizationAnswer{}, err
 	}
 
 	err = json.Unmarshal([]byte(openai_json), &summarizationAnswer)
 	if err != nil {
-		return "", false, err
+		return SummarizationAnswer{}, err
+	}
+
+	// Not every OpenAI-compatible server follows the schema
+	if summarizationAnswer.Probability == nil {
+		probability := 0.0
+		if summarizationAnswer.Unresolved {
+			probability = 0.5
+		} else if summarizationAnswer.YesNo {
+			probability = 1
+		}
+		summarizationAnswer.Probability = &probability
 	}
+	probability := math.Min(math.Max(*summarizationAnswer.Probability, 0), 1)
+	summarizationAnswer.Probability = &probability
 
 	if verbose {
 		log.Printf("[AnswerSummarizationQuestion] Question answered successfully")
 	}
-	return summarizationAnswer.Answer, summarizationAnswer.YesNo, nil
+	return summarizationAnswer, nil
 }
 
 type SimulationResult struct {
-	Question string
-	YesNo    bool
-	Answer   string
+	Question    string
+	YesNo       bool
+	Answer      string
+	Type        string   `json:",omitempty"` // Question type; empty for binary questions answered before types existed
+	Value       *float64 `json:",omitempty"` // Numeric questions
+	Choice      string   `json:",omitempty"` // Categorical questions
+	Date        string   `json:",omitempty"` // Date questions, YYYY-MM-DD
+	Probability *float64 `json:",omitempty"` // Binary questions, that the answer is yes
+	Unresolved  bool     `json:",omitempty"` // Binary questions the simulation did not settle, YesNo is then a guess; other questions without a valid answer
+}
+
+func runSingleSimulation(ctx context.Context, scenario Scenario, backend LLMBackend, saveDir string, logger SimLogger) ([]SimulationResult, error) {
+	return continueSimulation(ctx, scenario, SimulationProgress{}, backend, saveDir, logger)
 }
 
-func runSingleSimulation(situationDescription string, question string, numTurns int, client *openai.Client, saveDir string, logger SimLogger) (SimulationResult, error) {
+// continueSimulation runs whatever steps of a simulation are not already in
+// progress, so that interrupted simulations can be finished
+func continueSimulation(ctx context.Context, scenario Scenario, progress SimulationProgress, backend LLMBackend, saveDir string, logger SimLogger) ([]SimulationResult, error) {
 	// Use console logger if none provided
 	if logger == nil {
 		logger = &ConsoleLogger{}
 	}
-
-	// Step 1: Get initial actors
-	logger.Println("\n=== Generating Actors ===")
-	actors, err := GetActors(situationDescription, client)
+	calendar, err := scenario.Calendar()
 	if err != nil {
-		return SimulationResult{}, fmt.Errorf("failed to get actors: %v", err)
+		return nil, err
 	}
-	pretty_actors, _ := json.MarshalIndent(actors, "", "  ")
-	logger.Printf("%v\n", string(pretty_actors))
 
-	// Save actors if save directory is provided
-	if saveDir != "" {
-		actorsJSON, _ := json.MarshalIndent(actors, "", "  ")
-		ioutil.WriteFile(filepath.Join(saveDir, "actors.json"), actorsJSON, 0644)
+	// Step 1: Get initial actors
+	var actors Actors
+	if progress.Actors != nil {
+		actors = *progress.Actors
+		logger.Println("\n=== Resuming With Saved Actors ===")
+	} else {
+		logger.Println("\n=== Generating Actors ===")
+		generated, err := setupActors(ctx, scenario, backend)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get actors: %v", err)
+		}
+		actors = generated
+		pretty_actors, _ := json.MarshalIndent(actors, "", "  ")
+		logger.Printf("%v\n", string(pretty_actors))
+
+		// Save actors if save directory is provided
+		if saveDir != "" {
+			actorsJSON, _ := json.MarshalIndent(actors, "", "  ")
+			ioutil.WriteFile(filepath.Join(saveDir, "actors.json"), actorsJSON, 0644)
+		}
 	}
+	warnUnmatchedNegotiations(ctx, scenario.Negotiations, actors, logger)
 
 	// Step 2: Summarize initial world state
-	logger.Println("\n=== Initial World State ===")
-	worldState, err := SummarizeWorldState(situationDescription, actors, client)
-	if err != nil {
-		return SimulationResult{}, fmt.Errorf("failed to summarize world state: %v", err)
+	var worldState WorldState
+	if progress.WorldState != nil {
+		worldState = *progress.WorldState
+		logger.Printf("\n=== Resuming After Turn %d ===\n", len(progress.Actions))
+	} else {
+		logger.Println("\n=== Initial World State ===")
+		summarized, err := SummarizeWorldState(ctx, situationWithExternalInfo(scenario), actors, backend)
+		if err != nil {
+			return nil, fmt.Errorf("failed to summarize world state: %v", err)
+		}
+		worldState = summarized
+		worldState.Date = calendar.date(0)
+		worldState.Variables = initialValues(scenario.Variables)
+		pretty_world, _ := json.MarshalIndent(worldState, "", "  ")
+		logger.Printf("%v\n", string(pretty_world))
+
+		// Save initial world state if save directory is provided
+		if saveDir != "" {
+			worldStateJSON, _ := json.MarshalIndent(worldState, "", "  ")
+			ioutil.WriteFile(filepath.Join(saveDir, "initial_world_state.json"), worldStateJSON, 0644)
+		}
 	}
-	pretty_world, _ := json.MarshalIndent(worldState, "", "  ")
-	logger.Printf("%v\n", string(pretty_world))
 
 	// Step 3: Run simulation turns
-	var allActions [][]ActorAction
+	allActions := progress.Actions
+	memories := progress.Memories
+	if memories == nil {
+		memories = make(Memories)
+	}
 
-	for turn := 1; turn <= numTurns; turn++ {
-		logger.Printf("\n=== Simulation Turn %d ===\n", turn)
+	for turn := len(allActions) + 1; turn <= scenario.Turns; turn++ {
+		logger.Printf("\n=== Simulation Turn %d%s ===\n", turn, turnDates(calendar.Turn(turn)))
 
-		actions, newWorldState, err := RunSimulationTurn(worldState, actors, client, logger)
+		// Nature moves first, so the actors observe any shocks
+		shocks, shockedWorldState, err := Nature(ctx, scenario.turn(calendar, turn), worldState, shockRolls(scenario.Seed, simulationKeyOf(ctx), turn), backend)
 		if err != nil {
-			return SimulationResult{}, fmt.Errorf("failed to run simulation turn %d: %v", turn, err)
+			return nil, fmt.Errorf("failed to run nature in turn %d: %v", turn, err)
+		}
+		logShocks(logger, shocks)
+
+		actions, negotiations, newWorldState, err := RunSimulationTurn(ctx, scenario.turn(calendar, turn), shockedWorldState, actors, allActions, memories, backend, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to run simulation turn %d: %v", turn, err)
 		}
 
 		worldState = newWorldState
@@ -588,7 +877,11 @@ func runSingleSimulation(situationDescription string, question string, numTurns
 
 		logger.Printf("\nActions taken in turn %d:\n", turn)
 		for _, action := range actions {
-			logger.Printf("\n%s: %s\n", action.ActorName, action.Action)
+			name := action.ActorName
+			if action.Wait {
+				name += " (waits)"
+			}
+			logger.Printf("\n%s: %s\n", name, action.Action)
 			logger.Printf("Reasoning: %s\n", action.Reasoning)
 		}
 
@@ -604,49 +897,53 @@ func runSingleSimulation(situationDescription string, question string, numTurns
 			actionsJSON, _ := json.MarshalIndent(actions, "", "  ")
 			ioutil.WriteFile(filepath.Join(turnDir, "actions.json"), actionsJSON, 0644)
 
+			messagesJSON, _ := json.MarshalIndent(turnMessages(actions), "", "  ")
+			ioutil.WriteFile(filepath.Join(turnDir, "messages.json"), messagesJSON, 0644)
+
+			if len(negotiations) > 0 {
+				negotiationsJSON, _ := json.MarshalIndent(negotiations, "", "  ")
+				ioutil.WriteFile(filepath.Join(turnDir, "negotiations.json"), negotiationsJSON, 0644)
+			}
+
+			if len(shocks) > 0 {
+				shocksJSON, _ := json.MarshalIndent(shocks, "", "  ")
+				ioutil.WriteFile(filepath.Join(turnDir, "shocks.json"), shocksJSON, 0644)
+			}
+
+			// Before world_state.json, which marks the turn as complete
+			if err := saveMemories(saveDir, memories); err != nil {
+				logger.Printf("Warning: %v\n", err)
+			}
+
 			worldStateJSON, _ := json.MarshalIndent(worldState, "", "  ")
 			ioutil.WriteFile(filepath.Join(turnDir, "world_state.json"), worldStateJSON, 0644)
 		}
 	}
 
-	// Step 4: Answer summarization question
+	// Step 4: Answer summarization questions
 	logger.Println("\n=== Final Summarization ===")
-	answer, yesNo, err := AnswerSummarizationQuestion(question, worldState, allActions, client)
+	results, err := answerQuestions(ctx, scenario.AllQuestions(), worldState, allActions, backend)
 	if err != nil {
-		return SimulationResult{}, fmt.Errorf("failed to answer summarization question: %v", err)
+		return nil, fmt.Errorf("failed to answer summarization question: %v", err)
 	}
-	logger.Printf("\nQuestion: %s\n", question)
-	logger.Printf("Yes/No: %t\n", yesNo)
-	logger.Printf("Answer: %s\n", answer)
-
-	result := SimulationResult{
-		Question: question,
-		YesNo:    yesNo,
-		Answer:   answer,
+	for _, result := range results {
+		logger.Printf("\nQuestion: %s\n", result.Question)
+		logger.Printf("Outcome: %s\n", result.Outcome())
+		logger.Printf("Answer: %s\n", result.Answer)
 	}
 
-	// Save result if save directory is provided
+	// Save results if save directory is provided
 	if saveDir != "" {
-		resultJSON, _ := json.MarshalIndent(result, "", "  ")
+		resultJSON, _ := json.MarshalIndent(results, "", "  ")
 		ioutil.WriteFile(filepath.Join(saveDir, "result.json"), resultJSON, 0644)
 	}
 
-	return result, nil
+	return results, nil
 }
 
-func runInteractiveSimulation(client *openai.Client) error {
+func runInteractiveSimulation(ctx context.Context, scenario Scenario, backend LLMBackend) error {
 	reader := bufio.NewReader(os.Stdin)
-
-	// Get scenario from user
-	situationDescription := readInput("Enter the scenario description")
-
-	// Get number of turns
-	fmt.Print("\nEnter number of turns to simulate: ")
-	var numTurns int
-	fmt.Scanf("%d\n", &numTurns)
-
-	// Get summarization question
-	question := readInput("Enter the question to answer at the end")
+	scenario = scenario.seeded()
 
 	// Create session directory
 	sessionDir := fmt.Sprintf("session_%d", os.Getpid())
@@ -655,9 +952,15 @@ func runInteractiveSimulation(client *openai.Client) error {
 	}
 	fmt.Printf("\nSession directory: %s\n", sessionDir)
 
+	recorder := NewUsageRecorder(backend, UsageReport{})
+	backend = recorder
+	defer func() {
+		saveUsage(sessionDir, recorder.Report())
+	}()
+
 	// Step 1: Get and save actors
 	fmt.Println("\n=== Generating Actors ===")
-	actors, err := GetActors(situationDescription, client)
+	actors, err := setupActors(ctx, scenario, backend)
 	if err != nil {
 		return fmt.Errorf("failed to get actors: %v", err)
 	}
@@ -705,30 +1008,45 @@ func runInteractiveSimulation(client *openai.Client) error {
 
 	// Step 2: Summarize initial world state
 	fmt.Println("\n=== Initial World State ===")
-	worldState, err := SummarizeWorldState(situationDescription, actors, client)
+	worldState, err := SummarizeWorldState(ctx, situationWithExternalInfo(scenario), actors, backend)
 	if err != nil {
 		return fmt.Errorf("failed to summarize world state: %v", err)
 	}
+	calendar, err := scenario.Calendar()
+	if err != nil {
+		return err
+	}
+	worldState.Date = calendar.date(0)
+	worldState.Variables = initialValues(scenario.Variables)
 
 	// Step 3: Run simulation turns
 	var allActions [][]ActorAction
+	memories := make(Memories)
+	variableSeries := [][]VariableValue{worldState.Variables}
 
-	for turn := 1; turn <= numTurns; turn++ {
+	for turn := 1; turn <= scenario.Turns; turn++ {
 		turnDir := filepath.Join(sessionDir, fmt.Sprintf("turn_%d", turn))
 		if err := os.MkdirAll(turnDir, 0755); err != nil {
 			return fmt.Errorf("failed to create turn directory: %v", err)
 		}
 
-		fmt.Printf("\n=== Simulation Turn %d ===\n", turn)
+		fmt.Printf("\n=== Simulation Turn %d%s ===\n", turn, turnDates(calendar.Turn(turn)))
 
 		consoleLogger := &ConsoleLogger{}
-		actions, newWorldState, err := RunSimulationTurn(worldState, actors, client, consoleLogger)
+		shocks, shockedWorldState, err := Nature(ctx, scenario.turn(calendar, turn), worldState, shockRolls(scenario.Seed, "", turn), backend)
+		if err != nil {
+			return fmt.Errorf("failed to run nature in turn %d: %v", turn, err)
+		}
+		logShocks(consoleLogger, shocks)
+
+		actions, negotiations, newWorldState, err := RunSimulationTurn(ctx, scenario.turn(calendar, turn), shockedWorldState, actors, allActions, memories, backend, consoleLogger)
 		if err != nil {
 			return fmt.Errorf("failed to run simulation turn %d: %v", turn, err)
 		}
 
 		worldState = newWorldState
 		allActions = append(allActions, actions)
+		variableSeries = append(variableSeries, worldState.Variables)
 
 		// Save turn data to files
 		for i, action := range actions {
@@ -737,6 +1055,23 @@ func runInteractiveSimulation(client *openai.Client) error {
 			ioutil.WriteFile(actionFile, actionJSON, 0644)
 		}
 
+		messagesJSON, _ := json.MarshalIndent(turnMessages(actions), "", "  ")
+		ioutil.WriteFile(filepath.Join(turnDir, "messages.json"), messagesJSON, 0644)
+
+		if len(negotiations) > 0 {
+			negotiationsJSON, _ := json.MarshalIndent(negotiations, "", "  ")
+			ioutil.WriteFile(filepath.Join(turnDir, "negotiations.json"), negotiationsJSON, 0644)
+		}
+
+		if len(shocks) > 0 {
+			shocksJSON, _ := json.MarshalIndent(shocks, "", "  ")
+			ioutil.WriteFile(filepath.Join(turnDir, "shocks.json"), shocksJSON, 0644)
+		}
+
+		if err := saveMemories(sessionDir, memories); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+
 		worldStateJSON, _ := json.MarshalIndent(worldState, "", "  ")
 		worldStateFile := filepath.Join(turnDir, "world_state.json")
 		ioutil.WriteFile(worldStateFile, worldStateJSON, 0644)
@@ -746,26 +1081,29 @@ func runInteractiveSimulation(client *openai.Client) error {
 		reader.ReadString('\n')
 	}
 
-	// Step 4: Answer summarization question
+	// Step 4: Answer summarization questions
 	fmt.Println("\n=== Final Summarization ===")
-	answer, yesNo, err := AnswerSummarizationQuestion(question, worldState, allActions, client)
+	results, err := answerQuestions(ctx, scenario.AllQuestions(), worldState, allActions, backend)
 	if err != nil {
 		return fmt.Errorf("failed to answer summarization question: %v", err)
 	}
 
-	result := SimulationResult{
-		Question: question,
-		YesNo:    yesNo,
-		Answer:   answer,
-	}
-
-	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	resultJSON, _ := json.MarshalIndent(results, "", "  ")
 	resultFile := filepath.Join(sessionDir, "final_result.json")
 	ioutil.WriteFile(resultFile, resultJSON, 0644)
 
-	fmt.Printf("\nQuestion: %s\n", question)
-	fmt.Printf("Yes/No: %t\n", yesNo)
-	fmt.Printf("Answer: %s\n", answer)
+	for _, result := range results {
+		fmt.Printf("\nQuestion: %s\n", result.Question)
+		fmt.Printf("Outcome: %s\n", result.Outcome())
+		fmt.Printf("Answer: %s\n", result.Answer)
+	}
+	if len(scenario.Variables) > 0 {
+		fmt.Println("\n=== Variables ===")
+		for _, series := range summarizeVariables(scenario.Variables, [][][]VariableValue{variableSeries}) {
+			fmt.Print(plotVariableSeries(series))
+		}
+	}
+	printUsage(recorder.Report())
 	fmt.Printf("\nFinal result saved to %s\n", resultFile)
 
 	return nil
@@ -794,47 +1132,109 @@ func readInput(prompt string) string {
 	}
 }
 
-func runMultipleSimulations(numSimulations int, client *openai.Client) error {
-	// Get scenario from user
-	situationDescription := readInput("Enter the scenario description")
-
-	// Get number of turns
-	fmt.Print("\nEnter number of turns to simulate: ")
-	var numTurns int
-	fmt.Scanf("%d\n", &numTurns)
-
-	// Get summarization question
-	question := readInput("Enter the question to answer at the end")
+func runMultipleSimulations(ctx context.Context, scenario Scenario, numSimulations int, backend LLMBackend) error {
+	// Recorded in scenario.json, so that the batch can be replayed
+	scenario = scenario.seeded()
 
 	// Create base directory for all simulations
 	timestamp := time.Now().Format("20060102_150405")
 	baseDir := fmt.Sprintf("multi_sim_%s", timestamp)
+
+	var err error
+	if maxSimulations > 0 {
+		err = runAdaptiveSimulations(ctx, scenario, numSimulations, backend, baseDir)
+	} else {
+		err = runSimulationBatch(ctx, scenario, numSimulations, backend, baseDir)
+	}
+	if err != nil {
+		return err
+	}
+	recordForecasts(filepath.Join(baseDir, "aggregate_results.json"))
+	return nil
+}
+
+// runAdaptiveSimulations runs numSimulations simulations, then keeps adding
+// more until the yes interval of every binary question is at most targetWidth
+// wide, or there are maxSimulations of them
+func runAdaptiveSimulations(ctx context.Context, scenario Scenario, numSimulations int, backend LLMBackend, baseDir string) error {
+	n := numSimulations
+	if n > maxSimulations {
+		n = maxSimulations
+	}
+	for {
+		if err := runSimulationBatch(ctx, scenario, n, backend, baseDir); err != nil {
+			return err
+		}
+		var aggregate AggregateResult
+		if err := readJSON(filepath.Join(baseDir, "aggregate_results.json"), &aggregate); err != nil {
+			return fmt.Errorf("failed to read aggregate results: %v", err)
+		}
+
+		needed := simulationsNeeded(aggregate.Questions)
+		unresolved := unresolvedQuestions(aggregate.Questions)
+		if needed == 0 && len(unresolved) == 0 {
+			fmt.Printf("\nConverged: every yes/no interval is at most %g points wide after %d simulations\n", targetWidth, n)
+			return nil
+		}
+		if n >= maxSimulations {
+			if len(unresolved) > 0 {
+				fmt.Printf("\nStopped at --max-simulations %d without converging; no simulation resolved %q\n", maxSimulations, strings.Join(unresolved, `", "`))
+			} else {
+				fmt.Printf("\nStopped at --max-simulations %d without converging; about %d more simulations would narrow every yes/no interval to %g points\n", maxSimulations, needed, targetWidth)
+			}
+			return nil
+		}
+		// A question no simulation resolved has no interval to narrow, so
+		// there is no estimate of how many more are needed; double the batch
+		if len(unresolved) > 0 && needed < n {
+			needed = n
+		}
+		n += needed
+		if n > maxSimulations {
+			n = maxSimulations
+		}
+		if len(unresolved) > 0 {
+			fmt.Printf("\nNo simulation resolved %q yet, extending the batch to %d simulations\n", strings.Join(unresolved, `", "`), n)
+		} else {
+			fmt.Printf("\nIntervals are wider than %g points, extending the batch to %d simulations\n", targetWidth, n)
+		}
+	}
+}
+
+// runSimulationBatch runs numSimulations independent simulations in parallel,
+// saving each one under baseDir/simulation_N and the aggregate under baseDir
+func runSimulationBatch(ctx context.Context, scenario Scenario, numSimulations int, backend LLMBackend, baseDir string) error {
 	if err := os.MkdirAll(baseDir, 0755); err != nil {
 		return fmt.Errorf("failed to create base directory: %v", err)
 	}
 
 	fmt.Printf("\n=== Running %d Simulations in Parallel ===\n", numSimulations)
-	fmt.Printf("Scenario: %s\n", situationDescription)
-	fmt.Printf("Turns: %d\n", numTurns)
-	fmt.Printf("Question: %s\n", question)
+	fmt.Printf("Scenario: %s\n", scenario.Scenario)
+	fmt.Printf("Turns: %d\n", scenario.Turns)
+	for _, question := range scenario.AllQuestions() {
+		fmt.Printf("Question: %s\n", question.Text)
+	}
 	fmt.Printf("Saving to: %s\n", baseDir)
 
-	// Save scenario information to base directory
-	scenarioInfo := map[string]interface{}{
-		"scenario": situationDescription,
-		"question": question,
-		"turns":    numTurns,
-	}
-	scenarioJSON, _ := json.MarshalIndent(scenarioInfo, "", "  ")
+	// Save scenario information to base directory, in a form --scenario can load
+	scenario.NumSimulations = numSimulations
+	scenario.Models = &models
+	scenarioJSON, _ := json.MarshalIndent(scenario, "", "  ")
 	ioutil.WriteFile(filepath.Join(baseDir, "scenario.json"), scenarioJSON, 0644)
 
 	// Run simulations in parallel
 	type simResult struct {
-		index  int
-		result SimulationResult
-		err    error
+		index       int
+		result      []SimulationResult
+		err         error
+		stopped     bool // By the budget
+		interrupted bool // By cancelling ctx
 	}
 
+	// Cancelled on Ctrl-C, or to abort the batch after too many failures
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	resultsChan := make(chan simResult, numSimulations)
 	var wg sync.WaitGroup
 
@@ -843,10 +1243,41 @@ func runMultipleSimulations(numSimulations int, client *openai.Client) error {
 		go func(simIndex int) {
 			defer wg.Done()
 
-			fmt.Printf("Starting simulation %d/%d...\n", simIndex+1, numSimulations)
+			simDir := filepath.Join(baseDir, fmt.Sprintf("simulation_%d", simIndex+1))
+
+			// Reuse whatever an earlier, interrupted run of this batch saved
+			if result, ok := loadSimulationResults(simDir, scenario.AllQuestions()); ok {
+				fmt.Printf("Simulation %d/%d already complete\n", simIndex+1, numSimulations)
+				resultsChan <- simResult{
+					index:  simIndex,
+					result: result,
+				}
+				return
+			}
+			if ctx.Err() != nil {
+				resultsChan <- simResult{
+					index:       simIndex,
+					err:         fmt.Errorf("not started: %v", ctx.Err()),
+					interrupted: true,
+				}
+				return
+			}
+			if err := budgetError(backend); err != nil {
+				resultsChan <- simResult{
+					index:   simIndex,
+					err:     fmt.Errorf("not started: %v", err),
+					stopped: true,
+				}
+				return
+			}
+			progress := loadSimulationProgress(simDir)
+			if progress.Actors != nil {
+				fmt.Printf("Resuming simulation %d/%d after turn %d...\n", simIndex+1, numSimulations, len(progress.Actions))
+			} else {
+				fmt.Printf("Starting simulation %d/%d...\n", simIndex+1, numSimulations)
+			}
 
 			// Create directory for this simulation
-			simDir := filepath.Join(baseDir, fmt.Sprintf("simulation_%d", simIndex+1))
 			if err := os.MkdirAll(simDir, 0755); err != nil {
 				resultsChan <- simResult{
 					index: simIndex,
@@ -855,8 +1286,8 @@ func runMultipleSimulations(numSimulations int, client *openai.Client) error {
 				return
 			}
 
-			// Create log file for this simulation
-			logFile, err := os.Create(filepath.Join(simDir, "simulation.log"))
+			// Create log file for this simulation, keeping the log of any earlier run
+			logFile, err := os.OpenFile(filepath.Join(simDir, "simulation.log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
 			if err != nil {
 				resultsChan <- simResult{
 					index: simIndex,
@@ -869,11 +1300,17 @@ func runMultipleSimulations(numSimulations int, client *openai.Client) error {
 			// Create file logger for this simulation
 			logger := NewFileLogger(logFile)
 
-			result, err := runSingleSimulation(situationDescription, question, numTurns, client, simDir, logger)
+			// Record usage on top of whatever an earlier run of this simulation used
+			recorder := NewUsageRecorder(backend, loadUsage(simDir))
+			simCtx := withSimulationKey(ctx, fmt.Sprintf("simulation_%d", simIndex+1))
+			result, err := continueSimulation(simCtx, scenario, progress, recorder, simDir, logger)
+			saveUsage(simDir, recorder.Report())
 			if err != nil {
 				resultsChan <- simResult{
-					index: simIndex,
-					err:   fmt.Errorf("simulation failed: %v", err),
+					index:       simIndex,
+					err:         fmt.Errorf("simulation failed: %v", err),
+					stopped:     budgetError(backend) != nil,
+					interrupted: ctx.Err() != nil,
 				}
 				return
 			}
@@ -893,48 +1330,141 @@ func runMultipleSimulations(numSimulations int, client *openai.Client) error {
 		close(resultsChan)
 	}()
 
-	// Collect results
-	results := make([]SimulationResult, numSimulations)
-	yesCount := 0
+	// Collect results. Failed simulations are recorded and left out of the
+	// statistics, unless there are more than maxFailures of them. Simulations
+	// stopped by the budget or interrupted are recorded but don't count as failed.
+	results := make([][]SimulationResult, numSimulations)
+	var failures []SimulationFailure
+	failed, stopped, interrupted := 0, 0, 0
+	var abortErr error
 	for res := range resultsChan {
+		if abortErr != nil {
+			// Wait for the cancelled simulations to return
+			continue
+		}
+		if res.interrupted {
+			interrupted++
+			failures = append(failures, SimulationFailure{Simulation: res.index + 1, Error: res.err.Error(), Interrupted: true})
+			continue
+		}
+		if res.stopped {
+			fmt.Printf("Simulation %d/%d stopped: %v\n", res.index+1, numSimulations, res.err)
+			stopped++
+			failures = append(failures, SimulationFailure{Simulation: res.index + 1, Error: res.err.Error(), Stopped: true})
+			continue
+		}
 		if res.err != nil {
-			return fmt.Errorf("simulation %d failed: %v", res.index+1, res.err)
+			fmt.Printf("Simulation %d/%d failed: %v\n", res.index+1, numSimulations, res.err)
+			failed++
+			failures = append(failures, SimulationFailure{Simulation: res.index + 1, Error: res.err.Error()})
+			if maxFailures >= 0 && failed > maxFailures {
+				abortErr = fmt.Errorf("aborting: %d simulations failed, more than --max-failures %d (last error: %v; resume with --resume %s)", failed, maxFailures, res.err, baseDir)
+				cancel()
+			}
+			continue
 		}
 		results[res.index] = res.result
-		if res.result.YesNo {
-			yesCount++
-		}
 	}
+	if abortErr != nil {
+		return abortErr
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Simulation < failures[j].Simulation })
 
 	// Aggregate results
-	aggregateResult := map[string]interface{}{
-		"question":           question,
-		"total":              numSimulations,
-		"yes_count":          yesCount,
-		"no_count":           numSimulations - yesCount,
-		"yes_percentage":     float64(yesCount) / float64(numSimulations) * 100,
-		"scenario":           situationDescription,
-		"turns":              numTurns,
-		"individual_results": results,
+	questions := scenario.AllQuestions()
+	var variableRuns [][][]VariableValue
+	shockDraws := make([][]ShockDraw, numSimulations)
+	for i, simulationResults := range results {
+		if simulationResults != nil {
+			simDir := filepath.Join(baseDir, fmt.Sprintf("simulation_%d", i+1))
+			variableRuns = append(variableRuns, loadVariableSeries(simDir))
+			shockDraws[i] = loadShockDraws(simDir)
+		}
+	}
+	aggregateResult := AggregateResult{
+		Scenario:          scenario.Scenario,
+		Turns:             scenario.Turns,
+		Total:             numSimulations,
+		Successful:        numSimulations - failed - stopped - interrupted,
+		Failed:            failed,
+		Stopped:           stopped,
+		Interrupted:       interrupted,
+		Failures:          failures,
+		Questions:         aggregateAnswers(questions, results),
+		Usage:             batchUsage(baseDir, numSimulations),
+		Variables:         summarizeVariables(scenario.Variables, variableRuns),
+		Shocks:            breakDownByShock(scenario.Shocks, questions, shockDraws, results),
+		IndividualResults: results,
 	}
 
 	aggregateJSON, _ := json.MarshalIndent(aggregateResult, "", "  ")
 	ioutil.WriteFile(filepath.Join(baseDir, "aggregate_results.json"), aggregateJSON, 0644)
 
 	fmt.Printf("\n\n=== AGGREGATE RESULTS ===\n")
-	fmt.Printf("Question: %s\n", question)
 	fmt.Printf("Total simulations: %d\n", numSimulations)
-	fmt.Printf("Yes count: %d\n", yesCount)
-	fmt.Printf("No count: %d\n", numSimulations-yesCount)
-	fmt.Printf("Yes percentage: %.1f%%\n", float64(yesCount)/float64(numSimulations)*100)
+	if failed > 0 {
+		fmt.Printf("Failed simulations: %d\n", failed)
+	}
+	if stopped > 0 {
+		fmt.Printf("Simulations stopped by the budget: %d\n", stopped)
+	}
+	if interrupted > 0 {
+		fmt.Printf("Simulations interrupted: %d\n", interrupted)
+	}
+	if len(failures) > 0 {
+		fmt.Printf("Statistics are over the %d successful simulations\n", aggregateResult.Successful)
+	}
+	for _, aggregate := range aggregateResult.Questions {
+		printQuestionAggregate(aggregate)
+	}
+	if len(aggregateResult.Variables) > 0 {
+		fmt.Printf("\nVariables by turn, median and 10th to 90th percentile:\n")
+		for _, series := range aggregateResult.Variables {
+			fmt.Print(plotVariableSeries(series))
+		}
+	}
+	for _, breakdown := range aggregateResult.Shocks {
+		printShockBreakdown(breakdown)
+	}
+	printUsage(aggregateResult.Usage)
 	fmt.Printf("\nResults saved to: %s\n", baseDir)
+	if len(failures) > 0 {
+		fmt.Printf("Rerun the unfinished simulations with: --resume %s\n", baseDir)
+	}
 
 	// Print one-paragraph summary for each simulation
 	fmt.Printf("\n=== INDIVIDUAL SIMULATION SUMMARIES ===\n")
-	for i, result := range results {
-		fmt.Printf("\nSimulation %d: %t - %s\n", i+1, result.YesNo, result.Answer)
+	for q, question := range questions {
+		if len(questions) > 1 {
+			fmt.Printf("\n--- %s ---\n", question.Text)
+		}
+		for i, simulationResults := range results {
+			if simulationResults == nil {
+				continue
+			}
+			fmt.Printf("\nSimulation %d: %s - %s\n", i+1, simulationResults[q].Outcome(), simulationResults[q].Answer)
+		}
+	}
+	for _, failure := range failures {
+		status := "FAILED"
+		if failure.Stopped {
+			status = "STOPPED"
+		}
+		if failure.Interrupted {
+			status = "INTERRUPTED"
+		}
+		fmt.Printf("\nSimulation %d: %s - %s\n", failure.Simulation, status, failure.Error)
 	}
 
+	if interrupted > 0 {
+		return fmt.Errorf("interrupted; %d of %d simulations finished (resume with --resume %s to run the rest)", aggregateResult.Successful, numSimulations, baseDir)
+	}
+	if stopped > 0 {
+		return fmt.Errorf("%v; %d of %d simulations finished (resume with --resume %s to run the rest)", budgetError(backend), aggregateResult.Successful, numSimulations, baseDir)
+	}
+	if aggregateResult.Successful == 0 {
+		return fmt.Errorf("all %d simulations failed (first error: %s; resume with --resume %s)", numSimulations, failures[0].Error, baseDir)
+	}
 	return nil
 }
 
@@ -944,10 +1474,153 @@ func main() {
 	numSimulations := flag.Int("num-simulations", 0, "Run multiple simulations and aggregate results")
 	verboseFlag := flag.Bool("verbose", false, "Enable verbose logging")
 	multilineFlag := flag.Bool("multiline", false, "Enable multiline input for scenarios and questions")
-	flag.Parse()
+	modelFlag := flag.String("model", GPT5_2, "Model to use for all LLM calls, unless overridden per stage")
+	modelsFile := flag.String("models", "", "JSON file with a model per stage, e.g. {\"filter\": \"gpt-5-mini\", \"answer\": \"gpt-5.2\"}")
+	var stageModels ModelConfig
+	flag.StringVar(&stageModels.Actors, "model-actors", "", "Model for generating and adjusting actors")
+	flag.StringVar(&stageModels.InitialState, "model-initial-state", "", "Model for summarizing the initial world state")
+	flag.StringVar(&stageModels.Filter, "model-filter", "", "Model for filtering the world state for each actor")
+	flag.StringVar(&stageModels.Action, "model-action", "", "Model for deciding actor actions")
+	flag.StringVar(&stageModels.Update, "model-update", "", "Model for updating the world state")
+	flag.StringVar(&stageModels.Nature, "model-nature", "", "Model for estimating the probabilities of shocks")
+	flag.StringVar(&stageModels.Answer, "model-answer", "", "Model for answering the final question")
+	maxAttemptsFlag := flag.Int("max-attempts", 5, "Attempts at each LLM request before giving up on transient errors (rate limits, server errors, timeouts, malformed replies)")
+	maxFailuresFlag := flag.Int("max-failures", -1, "Abort a batch of simulations once more than this many fail (default: never abort)")
+	cacheMode := flag.String("cache", CacheOff, "Cache LLM replies on disk: off, read (reuse cached replies, cache new ones), write (always call the model, cache the reply) or replay (only use cached replies)")
+	cacheDir := flag.String("cache-dir", ".llm_cache", "Directory for --cache")
+	budgetUSD := flag.Float64("budget-usd", 0, "Stop making LLM calls once they have cost this many US dollars (0: no limit)")
+	maxTokens := flag.Int("max-tokens", 0, "Stop making LLM calls once they have used this many tokens (0: no limit)")
+	var rateLimits RateLimits
+	flag.IntVar(&rateLimits.MaxInFlight, "max-in-flight", 16, "Maximum LLM requests in flight at once, across all simulations (0: no limit)")
+	flag.IntVar(&rateLimits.RequestsPerMinute, "rpm", 0, "Maximum LLM requests per minute (0: no limit)")
+	flag.IntVar(&rateLimits.TokensPerMinute, "tpm", 0, "Maximum LLM tokens per minute, estimated before each request (0: no limit)")
+	flag.Float64Var(&confidenceLevel, "confidence", 0.95, "Confidence level of the intervals on yes percentages")
+	flag.Float64Var(&targetWidth, "target-width", 10, "Width, in percentage points, that intervals on yes percentages should narrow to; used to estimate the simulations needed, and by --max-simulations")
+	maxSimulationsFlag := flag.Int("max-simulations", 0, "Adaptive mode: after --num-simulations, keep adding simulations, up to this many in total, until every yes/no interval is at most --target-width wide (0: off)")
+	resumeDir := flag.String("resume", "", "Finish an interrupted multi_sim_<timestamp> directory")
+	scenarioFile := flag.String("scenario", "", "JSON or YAML (.yaml, .yml) scenario file to run without prompting (e.g. a scenario.json from a previous run)")
+	baseURLFlag := flag.String("base-url", "", "Base URL of an OpenAI-compatible API, e.g. a local llama.cpp, vLLM or Ollama server (default: OPENAI_BASE_URL or api.openai.com)")
+	questionsFile := flag.String("questions-file", "", "JSON file with a list of questions for ask, e.g. [{\"text\": \"...\", \"type\": \"numeric\"}]")
+
+	flag.StringVar(&ledgerPath, "ledger", "", "Forecast ledger, e.g. forecasts.jsonl: yes/no forecasts of finished runs are recorded here, for resolve and report (empty: don't record)")
+
+	// Commands come first, followed by the usual flags:
+	//   ask [flags] multi_sim_dir [question ...]
+	//   resolve [flags] forecast_id yes|no
+	//   report [flags]
+	command := ""
+	args := os.Args[1:]
+	if len(args) > 0 && (args[0] == "ask" || args[0] == "resolve" || args[0] == "report") {
+		command, args = args[0], args[1:]
+	}
+	flag.CommandLine.Parse(args)
 
 	verbose = *verboseFlag
 	multiline = *multilineFlag
+	maxFailures = *maxFailuresFlag
+	maxSimulations = *maxSimulationsFlag
+	if confidenceLevel <= 0 || confidenceLevel >= 1 {
+		log.Fatalf("Error: --confidence must be between 0 and 1")
+	}
+	if targetWidth <= 0 {
+		log.Fatalf("Error: --target-width must be positive")
+	}
+	maxAttempts = *maxAttemptsFlag
+	if maxAttempts < 1 {
+		log.Fatalf("Error: --max-attempts must be at least 1")
+	}
+
+	if command == "resolve" || command == "report" {
+		if ledgerPath == "" {
+			log.Fatalf("Error: %s needs a --ledger", command)
+		}
+		ledger, err := LoadLedger(ledgerPath)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if command == "resolve" {
+			if flag.NArg() != 2 {
+				log.Fatalf("Usage: resolve [flags] forecast_id yes|no")
+			}
+			id, err := strconv.Atoi(flag.Arg(0))
+			if err != nil {
+				log.Fatalf("Error: forecast id %q is not a number", flag.Arg(0))
+			}
+			outcome, err := parseOutcome(flag.Arg(1))
+			if err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			if err := ledger.Resolve(id, outcome, time.Now()); err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			forecast, _ := ledger.Forecast(id)
+			fmt.Printf("Resolved forecast %d (%.1f%% for %q) as %s\n", id, forecast.Probability*100, forecast.Question, flag.Arg(1))
+		}
+		printLedgerReport(ledger)
+		return
+	}
+
+	if *resumeDir != "" {
+		if *interactive || *scenarioFile != "" {
+			log.Fatalf("Error: --resume cannot be used with --interactive or --scenario")
+		}
+		*scenarioFile = filepath.Join(*resumeDir, "scenario.json")
+	}
+
+	var askDir string
+	var askQuestions []Question
+	if command == "ask" {
+		if flag.NArg() < 1 {
+			log.Fatalf("Usage: ask [flags] multi_sim_dir [question ...]")
+		}
+		if *interactive || *scenarioFile != "" || *resumeDir != "" || *numSimulations > 0 {
+			log.Fatalf("Error: ask cannot be used with --interactive, --scenario, --resume or --num-simulations")
+		}
+		askDir = flag.Arg(0)
+		for _, text := range flag.Args()[1:] {
+			askQuestions = append(askQuestions, Question{Text: text})
+		}
+		if *questionsFile != "" {
+			loaded, err := LoadQuestions(*questionsFile)
+			if err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			askQuestions = append(askQuestions, loaded...)
+		}
+		if len(askQuestions) == 0 {
+			log.Fatalf("Error: ask needs a question, as an argument or in --questions-file")
+		}
+		*scenarioFile = filepath.Join(askDir, "scenario.json")
+	}
+
+	var scenario Scenario
+	if *scenarioFile != "" {
+		loaded, err := LoadScenario(*scenarioFile)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		scenario = loaded
+	}
+
+	models = DefaultModelConfig(*modelFlag)
+	modelSet := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "model" {
+			modelSet = true
+		}
+	})
+	if scenario.Models != nil && !modelSet {
+		// Reuse the models recorded in the scenario, unless --model says otherwise
+		models = models.Override(*scenario.Models)
+	}
+	if *modelsFile != "" {
+		config, err := LoadModelConfig(*modelsFile, models)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		models = config
+	}
+	models = models.Override(stageModels)
 
 	if err := godotenv.Load(".env"); err != nil {
 		if verbose {
@@ -955,34 +1628,83 @@ func main() {
 		}
 	}
 	openaiToken := os.Getenv("OPENAI_API_KEY")
+	baseURL := *baseURLFlag
+	if baseURL == "" {
+		baseURL = os.Getenv("OPENAI_BASE_URL")
+	}
 
-	// Create OpenAI client once for reuse
-	client := openai.NewClient(openaiToken)
+	// Create LLM backend once for reuse
+	var backend LLMBackend = NewOpenAIBackend(openaiToken, baseURL, NewRateLimiter(rateLimits))
+	if *cacheMode != CacheOff {
+		cache, err := NewCachingBackend(backend, *cacheDir, *cacheMode)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		backend = cache
+	}
+	if *budgetUSD > 0 || *maxTokens > 0 {
+		backend = NewBudgetBackend(backend, *budgetUSD, *maxTokens)
+	}
 
 	if *interactive && *numSimulations > 0 {
 		log.Fatalf("Error: --interactive and --num-simulations flags cannot be used together")
 	}
 
-	if *interactive {
+	if *scenarioFile == "" && (*interactive || *numSimulations > 0) {
+		scenario = readScenario()
+	}
+
+	// The first Ctrl-C cancels ctx, so that running simulations stop and save
+	// what they have; a second one kills the process
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		signal.Stop(signals)
+		fmt.Println("\nInterrupted, stopping simulations... (press Ctrl-C again to quit immediately)")
+		cancel()
+	}()
+
+	if command == "ask" {
+		// Answer new questions against a finished batch of simulations
+		aggregatePath, err := askSimulations(ctx, askDir, scenario, askQuestions, backend)
+		if err != nil {
+			log.Fatalf("Asking simulations failed: %v", err)
+		}
+		recordForecasts(aggregatePath)
+	} else if *resumeDir != "" {
+		// Finish an interrupted batch of simulations
+		if err := resumeMultipleSimulations(ctx, *resumeDir, scenario, *numSimulations, backend); err != nil {
+			log.Fatalf("Resuming simulations failed: %v", err)
+		}
+	} else if *interactive {
 		// Run in interactive mode
-		if err := runInteractiveSimulation(client); err != nil {
+		if err := runInteractiveSimulation(ctx, scenario, backend); err != nil {
 			log.Fatalf("Interactive simulation failed: %v", err)
 		}
-	} else if *numSimulations > 0 {
-		// Run multiple simulations
-		if err := runMultipleSimulations(*numSimulations, client); err != nil {
+	} else if *numSimulations > 0 || *scenarioFile != "" {
+		// Run multiple simulations. Scenario files always run headless, by
+		// default as many times as they ask for.
+		n := *numSimulations
+		if *scenarioFile != "" && n == 0 {
+			n = scenario.NumSimulations
+			if n == 0 {
+				n = 1
+			}
+		}
+		if err := runMultipleSimulations(ctx, scenario, n, backend); err != nil {
 			log.Fatalf("Multiple simulations failed: %v", err)
 		}
 	} else {
 		// Run single simulation with default scenario
-		situationDescription := "The Bank of Japan is considering what to do about rates. I am curious about how to balance the central bank of Japan changing rates with the needs of the Japanese people, the PM, but also possible external pressure to not unwind the Japanese carry trade."
-		question := "Did the Bank of Japan raise rates, potentially unwinding the Japanese carry trade?"
-		numTurns := 2
-
-		_, err := runSingleSimulation(situationDescription, question, numTurns, client, "", nil)
+		recorder := NewUsageRecorder(backend, UsageReport{})
+		_, err := runSingleSimulation(ctx, defaultScenario, recorder, "", nil)
 		if err != nil {
 			log.Fatalf("Simulation failed: %v", err)
 		}
+		printUsage(recorder.Report())
 	}
 }
 
@@ -0,0 +1,572 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// discardLogger keeps simulation output out of test logs
+type discardLogger struct{}
+
+func (discardLogger) Printf(format string, v ...interface{}) {}
+func (discardLogger) Println(v ...interface{})               {}
+
+func testScenario(turns int) Scenario {
+	return Scenario{Scenario: "scenario", Question: "question?", Turns: turns}
+}
+
+func makeActors(n int) Actors {
+	var actors Actors
+	for i := 1; i <= n; i++ {
+		actors.Actors = append(actors.Actors, Actor{
+			Name:   fmt.Sprintf("Actor %d", i),
+			Goals:  "goals",
+			Powers: "powers",
+		})
+	}
+	return actors
+}
+
+// actorNameInPrompt returns the name of the actor whose JSON appears in prompt
+func actorNameInPrompt(prompt string, actors Actors) string {
+	for _, actor := range actors.Actors {
+		if strings.Contains(prompt, fmt.Sprintf(`"name":%q`, actor.Name)) {
+			return actor.Name
+		}
+	}
+	return ""
+}
+
+// echoActorHandler answers ActorAction requests with the requesting actor's
+// name, so tests can check that actions end up in the right slot
+func echoActorHandler(actors Actors) func(req LLMRequest) (string, error) {
+	return func(req LLMRequest) (string, error) {
+		if req.Schema.Name == "ActorAction" {
+			name := actorNameInPrompt(req.Prompt, actors)
+			return fmt.Sprintf(`{"actor_name": %q, "action": "action by %s", "reasoning": "because"}`, name, name), nil
+		}
+		return defaultFakeResponses[req.Schema.Name][0], nil
+	}
+}
+
+func readJSONFile(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("failed to unmarshal %s: %v", path, err)
+	}
+}
+
+func TestRunSimulationTurnFansOutOverActors(t *testing.T) {
+	tests := []struct {
+		name      string
+		numActors int
+	}{
+		{"single actor", 1},
+		{"three actors", 3},
+		{"ten actors", 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actors := makeActors(tt.numActors)
+			fake := NewFakeBackend()
+			fake.Handler = echoActorHandler(actors)
+
+			actions, _, _, err := RunSimulationTurn(context.Background(), Turn{Number: 1}, WorldState{Description: "start"}, actors, nil, nil, fake, nil)
+			if err != nil {
+				t.Fatalf("RunSimulationTurn returned error: %v", err)
+			}
+
+			if len(actions) != tt.numActors {
+				t.Fatalf("got %d actions, want %d", len(actions), tt.numActors)
+			}
+			for i, action := range actions {
+				if action.ActorName != actors.Actors[i].Name {
+					t.Errorf("action %d belongs to %q, want %q", i, action.ActorName, actors.Actors[i].Name)
+				}
+			}
+			if got := fake.CallCount("ActorView"); got != tt.numActors {
+				t.Errorf("ActorView calls = %d, want %d", got, tt.numActors)
+			}
+			if got := fake.CallCount("ActorAction"); got != tt.numActors {
+				t.Errorf("ActorAction calls = %d, want %d", got, tt.numActors)
+			}
+			if got := fake.CallCount("WorldState"); got != 1 {
+				t.Errorf("WorldState calls = %d, want 1", got)
+			}
+		})
+	}
+}
+
+func TestRunSimulationTurnRunsActorsInParallel(t *testing.T) {
+	const numActors = 4
+	actors := makeActors(numActors)
+	fake := NewFakeBackend()
+
+	// Every ActorView call blocks until all actors are filtering at once,
+	// which only happens if the turn fans out in parallel
+	var arrived sync.WaitGroup
+	arrived.Add(numActors)
+	allArrived := make(chan struct{})
+	go func() {
+		arrived.Wait()
+		close(allArrived)
+	}()
+	fake.Handler = func(req LLMRequest) (string, error) {
+		if req.Schema.Name == "ActorView" {
+			arrived.Done()
+			select {
+			case <-allArrived:
+			case <-time.After(5 * time.Second):
+				return "", errors.New("actors were not filtered concurrently")
+			}
+		}
+		return defaultFakeResponses[req.Schema.Name][0], nil
+	}
+
+	if _, _, _, err := RunSimulationTurn(context.Background(), Turn{Number: 1}, WorldState{}, actors, nil, nil, fake, nil); err != nil {
+		t.Fatalf("RunSimulationTurn returned error: %v", err)
+	}
+}
+
+func TestActorsDecideOnTheirCadence(t *testing.T) {
+	actors := makeActors(2)
+	actors.Actors[1].Cadence = 2 // Decides on turns 1 and 3
+	fake := NewFakeBackend()
+	fake.Handler = func(req LLMRequest) (string, error) {
+		if req.Schema.Name == "ActorAction" {
+			return fmt.Sprintf(`{"actor_name": %q, "action": "watches", "reasoning": "r", "wait": true}`, req.Actor), nil
+		}
+		return defaultFakeResponses[req.Schema.Name][0], nil
+	}
+
+	for turn := 1; turn <= 3; turn++ {
+		before := len(fake.Calls())
+		actions, _, _, err := RunSimulationTurn(context.Background(), Turn{Number: turn}, WorldState{}, actors, nil, nil, fake, nil)
+		if err != nil {
+			t.Fatalf("turn %d: RunSimulationTurn returned error: %v", turn, err)
+		}
+		asked := make(map[string]bool)
+		for _, call := range fake.Calls()[before:] {
+			if call.Stage == StageAction {
+				asked[call.Actor] = true
+			}
+		}
+		if due := turn != 2; asked["Actor 2"] != due || !asked["Actor 1"] {
+			t.Errorf("turn %d: asked %v, want Actor 2 asked: %v", turn, asked, due)
+		}
+		// Every actor has an action, in order, and waiting is kept
+		if len(actions) != 2 || actions[0].ActorName != "Actor 1" || actions[1].ActorName != "Actor 2" || !actions[0].Wait || !actions[1].Wait {
+			t.Errorf("turn %d: actions = %+v", turn, actions)
+		}
+		if turn == 2 && !strings.Contains(actions[1].Reasoning, "next decision is on turn 3") {
+			t.Errorf("scheduled wait reasoning = %q", actions[1].Reasoning)
+		}
+	}
+}
+
+func TestRunSimulationTurnPropagatesErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		failSchema string
+		wantErr    string
+	}{
+		{"filter fails", "ActorView", "failed to filter world state for Actor"},
+		{"action fails", "ActorAction", "failed to get action for Actor"},
+		{"update fails", "WorldState", "failed to update world state"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := NewFakeBackend()
+			fake.Errors[tt.failSchema] = errors.New("boom")
+			initial := WorldState{Description: "initial"}
+
+			_, _, worldState, err := RunSimulationTurn(context.Background(), Turn{Number: 1}, initial, makeActors(3), nil, nil, fake, nil)
+			if err == nil {
+				t.Fatalf("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) || !strings.Contains(err.Error(), "boom") {
+				t.Errorf("error %q does not mention %q and the cause", err, tt.wantErr)
+			}
+			if worldState.Description != initial.Description {
+				t.Errorf("world state changed on error: %+v", worldState)
+			}
+		})
+	}
+}
+
+func TestRunSingleSimulationTurnLoop(t *testing.T) {
+	tests := []struct {
+		name     string
+		numTurns int
+		yesNo    bool
+	}{
+		{"no turns", 0, false},
+		{"one turn", 1, true},
+		{"three turns", 3, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := NewFakeBackend()
+			fake.Responses["SummarizationAnswer"] = []string{fmt.Sprintf(`{"answer": "it happened", "yes_no": %t}`, tt.yesNo)}
+			// One reply for the initial summary, then one per turn
+			var worldStates []string
+			for turn := 0; turn <= tt.numTurns; turn++ {
+				worldStates = append(worldStates, fmt.Sprintf(`{"events": ["event %d"], "description": "after turn %d"}`, turn, turn))
+			}
+			fake.Responses["WorldState"] = worldStates
+			saveDir := t.TempDir()
+
+			results, err := runSingleSimulation(context.Background(), testScenario(tt.numTurns), fake, saveDir, discardLogger{})
+			if err != nil {
+				t.Fatalf("runSingleSimulation returned error: %v", err)
+			}
+
+			if len(results) != 1 {
+				t.Fatalf("got %d results, want 1", len(results))
+			}
+			if result := results[0]; result.Question != "question?" || result.YesNo != tt.yesNo || result.Answer != "it happened" {
+				t.Errorf("unexpected result: %+v", result)
+			}
+			// 2 actors per turn, each filtered and acting once
+			if got := fake.CallCount("ActorAction"); got != 2*tt.numTurns {
+				t.Errorf("ActorAction calls = %d, want %d", got, 2*tt.numTurns)
+			}
+			if got := fake.CallCount("WorldState"); got != tt.numTurns+1 {
+				t.Errorf("WorldState calls = %d, want %d", got, tt.numTurns+1)
+			}
+
+			// The final question sees the world state from the last turn
+			calls := fake.Calls()
+			last := calls[len(calls)-1]
+			if last.Schema.Name != "SummarizationAnswer" {
+				t.Fatalf("last call was %q, want SummarizationAnswer", last.Schema.Name)
+			}
+			if want := fmt.Sprintf("after turn %d", tt.numTurns); !strings.Contains(last.Prompt, want) {
+				t.Errorf("summarization prompt does not contain %q", want)
+			}
+
+			var actors Actors
+			readJSONFile(t, filepath.Join(saveDir, "actors.json"), &actors)
+			if len(actors.Actors) != 2 {
+				t.Errorf("actors.json has %d actors, want 2", len(actors.Actors))
+			}
+			for turn := 1; turn <= tt.numTurns; turn++ {
+				turnDir := filepath.Join(saveDir, fmt.Sprintf("turn_%d", turn))
+				var actions []ActorAction
+				readJSONFile(t, filepath.Join(turnDir, "actions.json"), &actions)
+				if len(actions) != 2 {
+					t.Errorf("turn %d: %d actions saved, want 2", turn, len(actions))
+				}
+				var worldState WorldState
+				readJSONFile(t, filepath.Join(turnDir, "world_state.json"), &worldState)
+				if want := fmt.Sprintf("after turn %d", turn); worldState.Description != want {
+					t.Errorf("turn %d: world state %q, want %q", turn, worldState.Description, want)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(saveDir, fmt.Sprintf("turn_%d", tt.numTurns+1))); !os.IsNotExist(err) {
+				t.Errorf("unexpected directory for turn %d", tt.numTurns+1)
+			}
+			var saved []SimulationResult
+			readJSONFile(t, filepath.Join(saveDir, "result.json"), &saved)
+			if len(saved) != 1 || !reflect.DeepEqual(saved[0], results[0]) {
+				t.Errorf("result.json = %+v, want %+v", saved, results)
+			}
+		})
+	}
+}
+
+func TestRunSingleSimulationPropagatesErrors(t *testing.T) {
+	tests := []struct {
+		failSchema string
+		wantErr    string
+	}{
+		{"Actors", "failed to get actors"},
+		{"WorldState", "failed to summarize world state"},
+		{"ActorView", "failed to run simulation turn 1"},
+		{"SummarizationAnswer", "failed to answer summarization question"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.failSchema, func(t *testing.T) {
+			fake := NewFakeBackend()
+			fake.Errors[tt.failSchema] = errors.New("boom")
+			saveDir := t.TempDir()
+
+			_, err := runSingleSimulation(context.Background(), testScenario(2), fake, saveDir, discardLogger{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if _, err := os.Stat(filepath.Join(saveDir, "result.json")); !os.IsNotExist(err) {
+				t.Errorf("result.json written for a failed simulation")
+			}
+		})
+	}
+}
+
+func TestRunSingleSimulationRejectsMalformedJSON(t *testing.T) {
+	fake := NewFakeBackend()
+	fake.Responses["ActorAction"] = []string{`{"actor_name": `}
+
+	_, err := runSingleSimulation(context.Background(), testScenario(1), fake, "", discardLogger{})
+	if err == nil {
+		t.Fatalf("expected error for malformed model output")
+	}
+}
+
+func TestRunSimulationBatchWritesFiles(t *testing.T) {
+	tests := []struct {
+		name           string
+		numSimulations int
+		answers        []bool
+		wantYes        int
+	}{
+		{"one simulation", 1, []bool{true}, 1},
+		{"mixed answers", 4, []bool{true, false, true, false}, 2},
+		{"all no", 3, []bool{false}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := NewFakeBackend()
+			var answers []string
+			for _, yes := range tt.answers {
+				answers = append(answers, fmt.Sprintf(`{"answer": "answer", "yes_no": %t}`, yes))
+			}
+			fake.Responses["SummarizationAnswer"] = answers
+			baseDir := filepath.Join(t.TempDir(), "multi_sim_test")
+
+			if err := runSimulationBatch(context.Background(), testScenario(2), tt.numSimulations, fake, baseDir); err != nil {
+				t.Fatalf("runSimulationBatch returned error: %v", err)
+			}
+
+			var scenario map[string]interface{}
+			readJSONFile(t, filepath.Join(baseDir, "scenario.json"), &scenario)
+			if scenario["scenario"] != "scenario" || scenario["question"] != "question?" || scenario["turns"] != float64(2) {
+				t.Errorf("unexpected scenario.json: %v", scenario)
+			}
+			if recorded, ok := scenario["models"].(map[string]interface{}); !ok || recorded["update"] != models.Update {
+				t.Errorf("scenario.json does not record the models used: %v", scenario["models"])
+			}
+
+			for i := 1; i <= tt.numSimulations; i++ {
+				simDir := filepath.Join(baseDir, fmt.Sprintf("simulation_%d", i))
+				for _, name := range []string{"actors.json", "result.json", "simulation.log", "turn_1/actions.json", "turn_1/world_state.json", "turn_2/actions.json", "turn_2/world_state.json"} {
+					if _, err := os.Stat(filepath.Join(simDir, name)); err != nil {
+						t.Errorf("simulation %d: missing %s", i, name)
+					}
+				}
+			}
+
+			var aggregate AggregateResult
+			readJSONFile(t, filepath.Join(baseDir, "aggregate_results.json"), &aggregate)
+			if aggregate.Total != tt.numSimulations || len(aggregate.Questions) != 1 {
+				t.Fatalf("unexpected aggregate: %+v", aggregate)
+			}
+			if counts := aggregate.Questions[0]; counts.YesCount != tt.wantYes || counts.NoCount != tt.numSimulations-tt.wantYes {
+				t.Errorf("unexpected aggregate counts: %+v", counts)
+			}
+			if len(aggregate.IndividualResults) != tt.numSimulations {
+				t.Errorf("aggregate has %d results, want %d", len(aggregate.IndividualResults), tt.numSimulations)
+			}
+		})
+	}
+}
+
+func TestRunSimulationBatchFailsWhenAllSimulationsFail(t *testing.T) {
+	fake := NewFakeBackend()
+	fake.Errors["Actors"] = errors.New("boom")
+	baseDir := filepath.Join(t.TempDir(), "multi_sim_test")
+
+	err := runSimulationBatch(context.Background(), testScenario(1), 2, fake, baseDir)
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("error = %v, want it to mention the failing call", err)
+	}
+
+	var aggregate AggregateResult
+	readJSONFile(t, filepath.Join(baseDir, "aggregate_results.json"), &aggregate)
+	if aggregate.Successful != 0 || aggregate.Failed != 2 || len(aggregate.Failures) != 2 {
+		t.Fatalf("unexpected aggregate: %+v", aggregate)
+	}
+	for i, failure := range aggregate.Failures {
+		if failure.Simulation != i+1 || !strings.Contains(failure.Error, "boom") {
+			t.Errorf("failure %d = %+v", i, failure)
+		}
+	}
+}
+
+// failFirstActors makes the first n Actors calls fail, and answers every other
+// call with the default fake response
+func failFirstActors(fake *FakeBackend, n int) {
+	var mu sync.Mutex
+	failed := 0
+	fake.Handler = func(req LLMRequest) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if req.Schema.Name == "Actors" && failed < n {
+			failed++
+			return "", errors.New("boom")
+		}
+		return defaultFakeResponses[req.Schema.Name][0], nil
+	}
+}
+
+func TestRunSimulationBatchToleratesFailures(t *testing.T) {
+	defer func(saved int) { maxFailures = saved }(maxFailures)
+
+	tests := []struct {
+		name        string
+		maxFailures int
+		wantErr     bool
+	}{
+		{"no limit", -1, false},
+		{"within limit", 2, false},
+		{"at limit", 1, false},
+		{"over limit", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxFailures = tt.maxFailures
+			fake := NewFakeBackend()
+			failFirstActors(fake, 1)
+			baseDir := filepath.Join(t.TempDir(), "multi_sim_test")
+
+			err := runSimulationBatch(context.Background(), testScenario(1), 3, fake, baseDir)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "--max-failures") {
+					t.Fatalf("error = %v, want a failure mentioning --max-failures", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("runSimulationBatch returned error: %v", err)
+			}
+
+			var aggregate AggregateResult
+			readJSONFile(t, filepath.Join(baseDir, "aggregate_results.json"), &aggregate)
+			if aggregate.Total != 3 || aggregate.Successful != 2 || aggregate.Failed != 1 || len(aggregate.Failures) != 1 {
+				t.Fatalf("unexpected aggregate: %+v", aggregate)
+			}
+			// Statistics only count the successful simulations
+			if counts := aggregate.Questions[0]; counts.YesCount != 2 || counts.NoCount != 0 {
+				t.Errorf("unexpected aggregate counts: %+v", counts)
+			}
+			failed := aggregate.Failures[0].Simulation
+			if aggregate.IndividualResults[failed-1] != nil {
+				t.Errorf("failed simulation %d has results: %+v", failed, aggregate.IndividualResults[failed-1])
+			}
+		})
+	}
+}
+
+func TestInterruptedBatchSavesPartialAggregate(t *testing.T) {
+	baseDir := filepath.Join(t.TempDir(), "multi_sim_test")
+	if err := runSimulationBatch(context.Background(), testScenario(2), 1, NewFakeBackend(), baseDir); err != nil {
+		t.Fatalf("runSimulationBatch returned error: %v", err)
+	}
+
+	// Extend the batch, and interrupt it as soon as the new simulations start
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fake := NewFakeBackend()
+	fake.Handler = func(req LLMRequest) (string, error) {
+		cancel()
+		return "", ctx.Err()
+	}
+	err := resumeMultipleSimulations(ctx, baseDir, testScenario(2), 3, fake)
+	if err == nil || !strings.Contains(err.Error(), "interrupted") || !strings.Contains(err.Error(), "--resume") {
+		t.Fatalf("error = %v, want an interruption suggesting --resume", err)
+	}
+
+	var aggregate AggregateResult
+	readJSONFile(t, filepath.Join(baseDir, "aggregate_results.json"), &aggregate)
+	if aggregate.Successful != 1 || aggregate.Failed != 0 || aggregate.Interrupted != 2 || len(aggregate.Failures) != 2 {
+		t.Fatalf("unexpected aggregate: %+v", aggregate)
+	}
+	for _, failure := range aggregate.Failures {
+		if !failure.Interrupted {
+			t.Errorf("simulation %d not marked as interrupted: %+v", failure.Simulation, failure)
+		}
+	}
+	if counts := aggregate.Questions[0]; counts.YesCount != 1 {
+		t.Errorf("unexpected aggregate counts: %+v", counts)
+	}
+}
+
+func TestAdaptiveSimulations(t *testing.T) {
+	defer func(saved float64) { targetWidth = saved }(targetWidth)
+	defer func(saved int) { maxSimulations = saved }(maxSimulations)
+
+	tests := []struct {
+		name            string
+		targetWidth     float64
+		maxSimulations  int
+		wantSimulations int
+	}{
+		// With every answer yes, the interval is under 60 points wide after 3
+		{"converges", 60, 10, 3},
+		{"stops at the cap", 5, 4, 4},
+		{"cap below the initial batch", 60, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targetWidth = tt.targetWidth
+			maxSimulations = tt.maxSimulations
+			fake := NewFakeBackend()
+			baseDir := filepath.Join(t.TempDir(), "multi_sim_test")
+
+			if err := runAdaptiveSimulations(context.Background(), testScenario(1), 2, fake, baseDir); err != nil {
+				t.Fatalf("runAdaptiveSimulations returned error: %v", err)
+			}
+			// Earlier simulations are reused, not rerun
+			if got := fake.CallCount("Actors"); got != tt.wantSimulations {
+				t.Errorf("%d simulations run, want %d", got, tt.wantSimulations)
+			}
+			var aggregate AggregateResult
+			readJSONFile(t, filepath.Join(baseDir, "aggregate_results.json"), &aggregate)
+			if aggregate.Total != tt.wantSimulations || aggregate.Questions[0].YesCount != tt.wantSimulations {
+				t.Errorf("aggregate over %d simulations (%d yes), want %d", aggregate.Total, aggregate.Questions[0].YesCount, tt.wantSimulations)
+			}
+		})
+	}
+}
+
+func TestAdaptiveSimulationsWithUnresolvedQuestion(t *testing.T) {
+	defer func(saved float64) { targetWidth = saved }(targetWidth)
+	defer func(saved int) { maxSimulations = saved }(maxSimulations)
+	targetWidth = 5
+	maxSimulations = 7
+
+	// With no resolved answer there is no interval, which must not count as converged
+	fake := NewFakeBackend()
+	fake.Responses["SummarizationAnswer"] = []string{`{"answer": "not yet", "yes_no": false, "unresolved": true, "probability": 0.5}`}
+	baseDir := filepath.Join(t.TempDir(), "multi_sim_test")
+
+	if err := runAdaptiveSimulations(context.Background(), testScenario(1), 2, fake, baseDir); err != nil {
+		t.Fatalf("runAdaptiveSimulations returned error: %v", err)
+	}
+	// 2, doubled to 4, then 8 capped at 7
+	if got := fake.CallCount("Actors"); got != 7 {
+		t.Errorf("%d simulations run, want the cap of 7", got)
+	}
+	var aggregate AggregateResult
+	readJSONFile(t, filepath.Join(baseDir, "aggregate_results.json"), &aggregate)
+	if aggregate.Questions[0].UnresolvedCount != 7 || aggregate.Questions[0].YesInterval != nil {
+		t.Errorf("aggregate = %+v, want 7 unresolved answers and no interval", aggregate.Questions[0])
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Number of an actor's latest turns that its memory digest gives in full;
+// earlier turns are cut down to what the actor did and planned
+const memoryDetailTurns = 3
+
+// MemoryEntry is what an actor went through in one turn it decided on
+type MemoryEntry struct {
+	Turn           int       `json:"turn"`
+	Date           string    `json:"date,omitempty"`
+	Observed       []string  `json:"observed"` // Visible events, including negotiations the actor took part in
+	Interpretation string    `json:"interpretation"`
+	Action         string    `json:"action"`
+	Waited         bool      `json:"waited,omitempty"`
+	Reasoning      string    `json:"reasoning"`
+	Plan           string    `json:"plan,omitempty"`
+	Received       []Message `json:"received,omitempty"`
+	Sent           []Message `json:"sent,omitempty"`
+}
+
+// ActorMemory is an actor's private journal of the turns it decided on
+type ActorMemory struct {
+	Actor   string        `json:"actor"`
+	Entries []MemoryEntry `json:"entries"`
+}
+
+// Memories holds each actor's memory, by actor name
+type Memories map[string]ActorMemory
+
+// record adds an entry to the named actor's memory
+func (m Memories) record(name string, entry MemoryEntry) {
+	memory := m[name]
+	memory.Actor = name
+	memory.Entries = append(memory.Entries, entry)
+	m[name] = memory
+}
+
+// digest summarizes the named actor's memory for its next decision, or
+// returns "" if it has none. The latest turns are given in full, earlier
+// ones only by what the actor did and planned.
+func (m Memories) digest(name string) string {
+	entries := m[name].Entries
+	var lines []string
+	for i, entry := range entries {
+		heading := fmt.Sprintf("Turn %d", entry.Turn)
+		if entry.Date != "" {
+			heading += fmt.Sprintf(" (%s)", entry.Date)
+		}
+		did := "Did: " + entry.Action
+		if entry.Waited {
+			did = "Waited: " + entry.Action
+		}
+		parts := []string{heading + ":"}
+		detailed := i >= len(entries)-memoryDetailTurns
+		if detailed {
+			parts = append(parts, "Saw: "+strings.Join(entry.Observed, "; ")+".", "Understood: "+entry.Interpretation)
+		}
+		parts = append(parts, did)
+		if detailed {
+			parts = append(parts, "Because: "+entry.Reasoning)
+		}
+		if entry.Plan != "" {
+			parts = append(parts, "Planned: "+entry.Plan)
+		}
+		if detailed {
+			for _, message := range entry.Received {
+				parts = append(parts, fmt.Sprintf("Received from %s: %s", message.From, message.Text))
+			}
+		}
+		for _, message := range entry.Sent {
+			parts = append(parts, fmt.Sprintf("Sent to %s: %s", strings.Join(message.To, ", "), message.Text))
+		}
+		lines = append(lines, strings.Join(parts, "\n  "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// memoryFileName is the file, under actor_memory/, that an actor's memory is
+// saved to. Spaces become underscores, and underscores, slashes and percent
+// signs are escaped, so that different names get different files.
+func memoryFileName(name string) string {
+	return strings.NewReplacer("%", "%25", "_", "%5F", "/", "%2F", `\`, "%5C", " ", "_").Replace(name) + ".json"
+}
+
+// checkActorNames fails if two actors would save their memories to the same
+// file, counting file systems that ignore case
+func checkActorNames(actors Actors) error {
+	for i, actor := range actors.Actors {
+		for _, other := range actors.Actors[:i] {
+			if strings.EqualFold(memoryFileName(actor.Name), memoryFileName(other.Name)) {
+				return fmt.Errorf("actors %q and %q have the same name, ignoring case", other.Name, actor.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// saveMemories writes each actor's memory to dir/actor_memory/<name>.json
+func saveMemories(dir string, memories Memories) error {
+	memoryDir := filepath.Join(dir, "actor_memory")
+	if err := os.MkdirAll(memoryDir, 0755); err != nil {
+		return fmt.Errorf("failed to create memory directory: %v", err)
+	}
+	for name, memory := range memories {
+		memoryJSON, _ := json.MarshalIndent(memory, "", "  ")
+		if err := ioutil.WriteFile(filepath.Join(memoryDir, memoryFileName(name)), memoryJSON, 0644); err != nil {
+			return fmt.Errorf("failed to save memory of %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// loadMemories reads the saved memories of the actors in simDir, keeping only
+// entries up to the given turn, since later turns will be rerun
+func loadMemories(simDir string, actors Actors, turns int) Memories {
+	memories := make(Memories)
+	for _, actor := range actors.Actors {
+		var memory ActorMemory
+		if err := readJSON(filepath.Join(simDir, "actor_memory", memoryFileName(actor.Name)), &memory); err != nil {
+			continue
+		}
+		var kept []MemoryEntry
+		for _, entry := range memory.Entries {
+			if entry.Turn <= turns {
+				kept = append(kept, entry)
+			}
+		}
+		memory.Entries = kept
+		memories[actor.Name] = memory
+	}
+	return memories
+}
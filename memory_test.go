@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMemoryDigest(t *testing.T) {
+	memories := make(Memories)
+	if got := memories.digest("A"); got != "" {
+		t.Errorf("digest of an empty memory = %q", got)
+	}
+	for turn := 1; turn <= 4; turn++ {
+		memories.record("A", MemoryEntry{
+			Turn:           turn,
+			Observed:       []string{fmt.Sprintf("seen %d", turn)},
+			Interpretation: "understood",
+			Action:         fmt.Sprintf("act %d", turn),
+			Reasoning:      "because",
+			Plan:           fmt.Sprintf("plan %d", turn),
+			Received:       []Message{{From: "B", To: []string{"A"}, Text: fmt.Sprintf("hello %d", turn)}},
+			Sent:           []Message{{From: "A", To: []string{"B"}, Text: fmt.Sprintf("reply %d", turn)}},
+		})
+	}
+	memories.record("A", MemoryEntry{Turn: 5, Date: "2026-02-01", Action: "watch", Waited: true})
+
+	digest := memories.digest("A")
+	// Every turn keeps what the actor did, planned and said
+	for _, want := range []string{"Turn 1:", "Did: act 1", "Planned: plan 1", "Sent to B: reply 1", "Turn 5 (2026-02-01):", "Waited: watch"} {
+		if !strings.Contains(digest, want) {
+			t.Errorf("digest does not contain %q:\n%s", want, digest)
+		}
+	}
+	// Only the latest turns keep what the actor saw and heard
+	for _, want := range []string{"Saw: seen 3", "Received from B: hello 4"} {
+		if !strings.Contains(digest, want) {
+			t.Errorf("digest does not contain %q:\n%s", want, digest)
+		}
+	}
+	for _, unwanted := range []string{"seen 2", "hello 2"} {
+		if strings.Contains(digest, unwanted) {
+			t.Errorf("digest contains %q from an early turn:\n%s", unwanted, digest)
+		}
+	}
+	if memories.digest("B") != "" {
+		t.Errorf("B has A's memories")
+	}
+}
+
+func TestMemoriesAreSavedAndResumed(t *testing.T) {
+	saveDir := t.TempDir()
+	scenario := testScenario(3)
+	actors := makeActors(2)
+	actors.Actors[1].Cadence = 2
+	scenario.Actors = &actors
+	fake := NewFakeBackend()
+	fake.Responses["ActorAction"] = []string{`{"actor_name": "Actor", "action": "fake action", "reasoning": "fake reasoning", "wait": false, "plan": "hold firm", "messages": []}`}
+	if _, err := runSingleSimulation(context.Background(), scenario, fake, saveDir, discardLogger{}); err != nil {
+		t.Fatalf("runSingleSimulation returned error: %v", err)
+	}
+
+	// Actor 2 only remembers the turns it decided on
+	for name, wantTurns := range map[string][]int{"Actor 1": {1, 2, 3}, "Actor 2": {1, 3}} {
+		var memory ActorMemory
+		readJSONFile(t, filepath.Join(saveDir, "actor_memory", memoryFileName(name)), &memory)
+		var turns []int
+		for _, entry := range memory.Entries {
+			turns = append(turns, entry.Turn)
+			if entry.Plan != "hold firm" || len(entry.Observed) == 0 {
+				t.Errorf("%s's memory of turn %d = %+v", name, entry.Turn, entry)
+			}
+		}
+		if memory.Actor != name || len(turns) != len(wantTurns) || turns[len(turns)-1] != wantTurns[len(wantTurns)-1] {
+			t.Errorf("%s remembers turns %v, want %v", name, turns, wantTurns)
+		}
+	}
+
+	// Only decisions after the first are made with a memory
+	withMemory := 0
+	for _, call := range fake.Calls() {
+		if call.Stage == StageAction && strings.Contains(call.Prompt, "Planned: hold firm") {
+			withMemory++
+		}
+	}
+	if withMemory != 3 {
+		t.Errorf("%d decisions made with a memory, want 3", withMemory)
+	}
+
+	// Memories of a turn that has to be rerun are forgotten
+	if err := os.Remove(filepath.Join(saveDir, "turn_3", "world_state.json")); err != nil {
+		t.Fatal(err)
+	}
+	progress := loadSimulationProgress(saveDir)
+	if got := len(progress.Memories["Actor 1"].Entries); got != 2 {
+		t.Errorf("resumed with %d turns of Actor 1's memory, want 2", got)
+	}
+	if got := len(progress.Memories["Actor 2"].Entries); got != 1 {
+		t.Errorf("resumed with %d turns of Actor 2's memory, want 1", got)
+	}
+}
+
+func TestMemoryFileNamesAreUnique(t *testing.T) {
+	names := []string{"Central Bank", "Central_Bank", "Central%5FBank", "A/B", "A B", `A\B`, "A_B"}
+	seen := make(map[string]string)
+	for _, name := range names {
+		file := memoryFileName(name)
+		if other, ok := seen[file]; ok {
+			t.Errorf("%q and %q are both saved to %s", other, name, file)
+		}
+		if strings.ContainsAny(file, `/\ `) {
+			t.Errorf("%q is saved to %q, which is not a plain file name", name, file)
+		}
+		seen[file] = name
+	}
+	if got := memoryFileName("Bank of Japan"); got != "Bank_of_Japan.json" {
+		t.Errorf("memoryFileName = %q, want names without underscores to keep their files", got)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+)
+
+// Recipient that addresses a message to every other actor
+const broadcastRecipient = "everyone"
+
+// Message is a private message from one actor to others, sent as part of an
+// action. Only its recipients read it, when they next decide; it is kept out
+// of the world state, and so out of every other actor's view.
+type Message struct {
+	From string   `json:"from"` // Set to the sending actor, whatever the model says
+	To   []string `json:"to"`   // Actor names, or "everyone"
+	Text string   `json:"text"`
+}
+
+// addressedTo reports whether the named actor receives the message. Senders
+// don't receive their own messages.
+func (m Message) addressedTo(name string) bool {
+	if strings.EqualFold(m.From, name) {
+		return false
+	}
+	for _, recipient := range m.To {
+		recipient = strings.TrimSpace(recipient)
+		if strings.EqualFold(recipient, name) || strings.EqualFold(recipient, broadcastRecipient) {
+			return true
+		}
+	}
+	return false
+}
+
+// turnMessages returns every message sent with the actions of a turn, which
+// is the turn's message log
+func turnMessages(actions []ActorAction) []Message {
+	messages := []Message{}
+	for _, action := range actions {
+		messages = append(messages, action.Messages...)
+	}
+	return messages
+}
+
+// inbox returns the messages sent to the actor since it last decided, given
+// the actions of every earlier turn. Messages sent on the turn the actor last
+// decided arrived after it did, so they are included too.
+func inbox(actor Actor, history [][]ActorAction) []Message {
+	var received []Message
+	for turn := len(history); turn >= 1; turn-- {
+		var messages []Message
+		for _, message := range turnMessages(history[turn-1]) {
+			if message.addressedTo(actor.Name) {
+				messages = append(messages, message)
+			}
+		}
+		// Oldest first
+		received = append(messages, received...)
+		if actor.actsOnTurn(turn) {
+			break
+		}
+	}
+	return received
+}
+
+// withoutMessages returns a copy of actions without their messages, for
+// prompts that every actor's view is derived from
+func withoutMessages(actions []ActorAction) []ActorAction {
+	public := make([]ActorAction, len(actions))
+	for i, action := range actions {
+		action.Messages = []Message{}
+		public[i] = action
+	}
+	return public
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMessageAddressedTo(t *testing.T) {
+	tests := []struct {
+		message Message
+		name    string
+		want    bool
+	}{
+		{Message{From: "PM", To: []string{"BoJ"}}, "BoJ", true},
+		{Message{From: "PM", To: []string{" boj "}}, "BoJ", true},
+		{Message{From: "PM", To: []string{"BoJ"}}, "Treasury", false},
+		{Message{From: "PM", To: []string{"BoJ", "Treasury"}}, "Treasury", true},
+		{Message{From: "PM", To: []string{"everyone"}}, "Treasury", true},
+		{Message{From: "PM", To: []string{"everyone"}}, "PM", false},
+		{Message{From: "PM", To: nil}, "BoJ", false},
+	}
+	for _, tt := range tests {
+		if got := tt.message.addressedTo(tt.name); got != tt.want {
+			t.Errorf("%+v addressed to %s = %v, want %v", tt.message, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInbox(t *testing.T) {
+	message := func(text, to string) Message { return Message{From: "Sender", To: []string{to}, Text: text} }
+	history := [][]ActorAction{
+		{{ActorName: "Sender", Messages: []Message{message("turn 1", "Slow")}}},
+		{{ActorName: "Sender", Messages: []Message{message("turn 2", "Slow"), message("to fast", "Fast")}}},
+		{{ActorName: "Sender", Messages: []Message{message("turn 3", "everyone")}}},
+	}
+
+	texts := func(messages []Message) []string {
+		var texts []string
+		for _, message := range messages {
+			texts = append(texts, message.Text)
+		}
+		return texts
+	}
+	// Fast decides every turn, so it only gets the last turn's messages
+	if got := texts(inbox(Actor{Name: "Fast"}, history)); !reflect.DeepEqual(got, []string{"turn 3"}) {
+		t.Errorf("Fast received %v", got)
+	}
+	// Slow last decided on turn 1, and gets everything sent since, oldest first
+	if got := texts(inbox(Actor{Name: "Slow", Cadence: 3}, history)); !reflect.DeepEqual(got, []string{"turn 1", "turn 2", "turn 3"}) {
+		t.Errorf("Slow received %v", got)
+	}
+	if got := inbox(Actor{Name: "Fast"}, nil); len(got) != 0 {
+		t.Errorf("received %v before the first turn", got)
+	}
+}
+
+func TestMessagesArePrivate(t *testing.T) {
+	saveDir := t.TempDir()
+	scenario := testScenario(2)
+	scenario.Actors = &Actors{Actors: makeActors(3).Actors}
+	fake := NewFakeBackend()
+	fake.Handler = func(req LLMRequest) (string, error) {
+		if req.Schema.Name == "ActorAction" && req.Actor == "Actor 1" {
+			// The model gets the sender wrong, which is corrected
+			return `{"actor_name": "Actor 1", "action": "calls", "reasoning": "r", "wait": false, "messages": [{"from": "Actor 3", "to": ["Actor 2"], "text": "secret offer"}]}`, nil
+		}
+		return defaultFakeResponses[req.Schema.Name][0], nil
+	}
+	if _, err := runSingleSimulation(context.Background(), scenario, fake, saveDir, discardLogger{}); err != nil {
+		t.Fatalf("runSingleSimulation returned error: %v", err)
+	}
+
+	received := make(map[string]int)
+	for _, call := range fake.Calls() {
+		if !strings.Contains(call.Prompt, "secret offer") {
+			continue
+		}
+		switch call.Stage {
+		case StageAction:
+			received[call.Actor]++
+		case StageAnswer:
+		default:
+			t.Errorf("message leaked into a %s prompt", call.Stage)
+		}
+	}
+	// Sent on both turns, but only the one sent on turn 1 has been read. The
+	// sender remembers sending it.
+	if want := map[string]int{"Actor 1": 1, "Actor 2": 1}; !reflect.DeepEqual(received, want) {
+		t.Errorf("actions prompted with the message: %v, want %v", received, want)
+	}
+
+	for turn := 1; turn <= 2; turn++ {
+		var messages []Message
+		readJSONFile(t, filepath.Join(saveDir, fmt.Sprintf("turn_%d", turn), "messages.json"), &messages)
+		want := []Message{{From: "Actor 1", To: []string{"Actor 2"}, Text: "secret offer"}}
+		if !reflect.DeepEqual(messages, want) {
+			t.Errorf("turn %d messages = %+v, want %+v", turn, messages, want)
+		}
+	}
+}
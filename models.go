@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// ModelConfig selects the model used at each stage of the simulation, so
+// that e.g. actor views can use a cheap model while world updates and the
+// final answer use a strong one
+type ModelConfig struct {
+	Actors       string `json:"actors"`        // GetActors, AdjustActors
+	InitialState string `json:"initial_state"` // SummarizeWorldState
+	Filter       string `json:"filter"`        // FilterWorldStateForActor
+	Action       string `json:"action"`        // ActorTakesAction
+	Update       string `json:"update"`        // UpdateWorldState
+	Nature       string `json:"nature"`        // EstimateShockProbabilities
+	Answer       string `json:"answer"`        // AnswerSummarizationQuestion
+}
+
+// Stage names, as in the JSON keys of ModelConfig
+const (
+	StageActors       = "actors"
+	StageInitialState = "initial_state"
+	StageFilter       = "filter"
+	StageAction       = "action"
+	StageUpdate       = "update"
+	StageNature       = "nature"
+	StageAnswer       = "answer"
+)
+
+// models is the configuration used by all core functions. It is set from the
+// command line in main.
+var models ModelConfig = DefaultModelConfig(GPT5_2)
+
+// DefaultModelConfig uses the same model for every stage
+func DefaultModelConfig(model string) ModelConfig {
+	return ModelConfig{
+		Actors:       model,
+		InitialState: model,
+		Filter:       model,
+		Action:       model,
+		Update:       model,
+		Nature:       model,
+		Answer:       model,
+	}
+}
+
+// LoadModelConfig reads a JSON file such as {"filter": "gpt-5-mini", "answer": "gpt-5.2"}.
+// Stages missing from the file keep the model they have in base.
+func LoadModelConfig(path string, base ModelConfig) (ModelConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ModelConfig{}, fmt.Errorf("failed to read model config: %v", err)
+	}
+	config := base
+	if err := json.Unmarshal(data, &config); err != nil {
+		return ModelConfig{}, fmt.Errorf("failed to parse model config %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// Override replaces the model for every stage with a non-empty entry in overrides
+func (c ModelConfig) Override(overrides ModelConfig) ModelConfig {
+	set := func(dst *string, src string) {
+		if src != "" {
+			*dst = src
+		}
+	}
+	set(&c.Actors, overrides.Actors)
+	set(&c.InitialState, overrides.InitialState)
+	set(&c.Filter, overrides.Filter)
+	set(&c.Action, overrides.Action)
+	set(&c.Update, overrides.Update)
+	set(&c.Nature, overrides.Nature)
+	set(&c.Answer, overrides.Answer)
+	return c
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadModelConfigKeepsUnsetStages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "models.json")
+	if err := os.WriteFile(path, []byte(`{"filter": "cheap", "answer": "strong"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadModelConfig(path, DefaultModelConfig("base"))
+	if err != nil {
+		t.Fatalf("LoadModelConfig returned error: %v", err)
+	}
+	want := ModelConfig{Actors: "base", InitialState: "base", Filter: "cheap", Action: "base", Update: "base", Nature: "base", Answer: "strong"}
+	if config != want {
+		t.Errorf("config = %+v, want %+v", config, want)
+	}
+
+	config = config.Override(ModelConfig{Update: "strongest"})
+	if config.Update != "strongest" || config.Filter != "cheap" {
+		t.Errorf("Override did not apply only the non-empty stage: %+v", config)
+	}
+}
+
+func TestStagesUseConfiguredModels(t *testing.T) {
+	saved := models
+	defer func() { models = saved }()
+	models = ModelConfig{Actors: "m-actors", InitialState: "m-initial", Filter: "m-filter", Action: "m-action", Update: "m-update", Nature: "m-nature", Answer: "m-answer"}
+
+	fake := NewFakeBackend()
+	scenario := testScenario(1)
+	scenario.Shocks = []Shock{{Name: "Shock", Event: "e"}} // Estimated by the model
+	if _, err := runSingleSimulation(context.Background(), scenario, fake, "", discardLogger{}); err != nil {
+		t.Fatalf("runSingleSimulation returned error: %v", err)
+	}
+
+	wantModels := map[string]map[string]bool{
+		"Actors":              {"m-actors": true},
+		"WorldState":          {"m-initial": true, "m-update": true},
+		"ActorView":           {"m-filter": true},
+		"ActorAction":         {"m-action": true},
+		"ShockEstimates":      {"m-nature": true},
+		"SummarizationAnswer": {"m-answer": true},
+	}
+	for _, call := range fake.Calls() {
+		if !wantModels[call.Schema.Name][call.Model] {
+			t.Errorf("%s request used model %q", call.Schema.Name, call.Model)
+		}
+		if call.Schema.Name == "ShockEstimates" && call.Stage != StageNature {
+			t.Errorf("ShockEstimates request has stage %q, want %q", call.Stage, StageNature)
+		}
+	}
+}
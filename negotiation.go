@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+	jsonschema "github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// Rounds a negotiation runs for when its scenario doesn't say
+const defaultNegotiationRounds = 5
+
+// Negotiation outcomes
+const (
+	NegotiationAgreement   = "agreement"
+	NegotiationBreakdown   = "breakdown"    // A party walked away
+	NegotiationNoAgreement = "no_agreement" // The rounds ran out
+)
+
+// Negotiation is a scenario's bargaining phase between some of its actors,
+// held inside a turn before they act
+type Negotiation struct {
+	Topic     string   `json:"topic"`
+	Parties   []string `json:"parties"`              // Actor names
+	MaxRounds int      `json:"max_rounds,omitempty"` // Default defaultNegotiationRounds
+	Turns     []int    `json:"turns,omitempty"`      // Turns it is held on; every turn if empty
+}
+
+// NegotiationMove is one party's move in a round of a negotiation
+type NegotiationMove struct {
+	Party     string `json:"party"` // Set to the actor making the move, whatever the model says
+	Round     int    `json:"round"` // Set by the negotiation
+	Statement string `json:"statement"`
+	Proposal  string `json:"proposal"` // New terms, if the party doesn't accept the ones on the table
+	Accept    bool   `json:"accept"`
+	WalkAway  bool   `json:"walk_away"`
+}
+
+// NegotiationOutcome is how a negotiation ended, with its full transcript
+type NegotiationOutcome struct {
+	Topic      string            `json:"topic"`
+	Parties    []string          `json:"parties"`
+	Status     string            `json:"status"` // agreement, breakdown or no_agreement
+	Terms      string            `json:"terms"`  // Agreed terms, or the last proposal on the table
+	ProposedBy string            `json:"proposed_by"`
+	Rounds     int               `json:"rounds"`
+	Transcript []NegotiationMove `json:"transcript"`
+}
+
+// validate checks that the negotiation is fully specified
+func (n Negotiation) validate() error {
+	if strings.TrimSpace(n.Topic) == "" {
+		return fmt.Errorf("negotiation without a topic")
+	}
+	if len(n.Parties) < 2 {
+		return fmt.Errorf("negotiation %q needs at least two parties", n.Topic)
+	}
+	for i, party := range n.Parties {
+		for _, other := range n.Parties[:i] {
+			// findParties would otherwise find the same actor twice
+			if strings.EqualFold(strings.TrimSpace(party), strings.TrimSpace(other)) {
+				return fmt.Errorf("negotiation %q has %q as a party twice", n.Topic, strings.TrimSpace(party))
+			}
+		}
+	}
+	if n.MaxRounds < 0 {
+		return fmt.Errorf("negotiation %q has a negative number of rounds", n.Topic)
+	}
+	for _, turn := range n.Turns {
+		if turn < 1 {
+			return fmt.Errorf("negotiation %q is held on turn %d; turns start at 1", n.Topic, turn)
+		}
+	}
+	return nil
+}
+
+func (n Negotiation) rounds() int {
+	if n.MaxRounds == 0 {
+		return defaultNegotiationRounds
+	}
+	return n.MaxRounds
+}
+
+// heldOnTurn reports whether the negotiation takes place on turn n
+func (n Negotiation) heldOnTurn(turn int) bool {
+	if len(n.Turns) == 0 {
+		return true
+	}
+	for _, t := range n.Turns {
+		if t == turn {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiationsOn returns the scenario's negotiations held on turn n
+func (s Scenario) negotiationsOn(turn int) []Negotiation {
+	var negotiations []Negotiation
+	for _, negotiation := range s.Negotiations {
+		if negotiation.heldOnTurn(turn) {
+			negotiations = append(negotiations, negotiation)
+		}
+	}
+	return negotiations
+}
+
+// Event describes the outcome as a world state event
+func (o NegotiationOutcome) Event() string {
+	parties := strings.Join(o.Parties, ", ")
+	switch o.Status {
+	case NegotiationAgreement:
+		return fmt.Sprintf("Negotiation on %s between %s: agreement reached after %d rounds on: %s", o.Topic, parties, o.Rounds, o.Terms)
+	case NegotiationBreakdown:
+		walkedAway := o.Transcript[len(o.Transcript)-1].Party
+		return fmt.Sprintf("Negotiation on %s between %s broke down after %d rounds when %s walked away", o.Topic, parties, o.Rounds, walkedAway)
+	}
+	if o.Terms == "" {
+		return fmt.Sprintf("Negotiation on %s between %s ended without agreement after %d rounds, with nothing proposed", o.Topic, parties, o.Rounds)
+	}
+	return fmt.Sprintf("Negotiation on %s between %s ended without agreement after %d rounds; the last proposal, by %s, was: %s", o.Topic, parties, o.Rounds, o.ProposedBy, o.Terms)
+}
+
+// Negotiate runs a negotiation between parties, each seeing the world through
+// their view. In each round the parties move in turn, each seeing the
+// transcript so far and remembering earlier turns through memories. A party
+// can accept the proposal on the table, make a new one, or walk away, which
+// ends the negotiation. It ends in agreement once every party accepts the
+// same proposal.
+func Negotiate(ctx context.Context, negotiation Negotiation, parties []Actor, views map[string]ActorView, memories Memories, turn Turn, backend LLMBackend, logger SimLogger) (NegotiationOutcome, error) {
+	outcome := NegotiationOutcome{Topic: negotiation.Topic, Status: NegotiationNoAgreement, Transcript: []NegotiationMove{}}
+	for _, party := range parties {
+		outcome.Parties = append(outcome.Parties, party.Name)
+	}
+
+	accepted := make(map[string]bool)
+	for round := 1; round <= negotiation.rounds(); round++ {
+		outcome.Rounds = round
+		for _, party := range parties {
+			move, err := NegotiationMoveFor(ctx, party, views[party.Name], memories.digest(party.Name), outcome, round, negotiation.rounds(), turn, backend)
+			if err != nil {
+				return NegotiationOutcome{}, fmt.Errorf("failed to get %s's move in round %d of negotiation %q: %v", party.Name, round, negotiation.Topic, err)
+			}
+			outcome.Transcript = append(outcome.Transcript, move)
+			if logger != nil {
+				logger.Printf("\n[Negotiation: %s, round %d] %s: %s\n", negotiation.Topic, round, party.Name, move.Statement)
+			}
+
+			switch {
+			case move.WalkAway:
+				outcome.Status = NegotiationBreakdown
+				return outcome, nil
+			case move.Accept && outcome.Terms != "":
+				accepted[party.Name] = true
+			case strings.TrimSpace(move.Proposal) != "":
+				outcome.Terms = move.Proposal
+				outcome.ProposedBy = party.Name
+				accepted = map[string]bool{party.Name: true}
+			}
+			if outcome.Terms != "" && len(accepted) == len(parties) {
+				outcome.Status = NegotiationAgreement
+				return outcome, nil
+			}
+		}
+	}
+	return outcome, nil
+}
+
+// NegotiationMoveFor asks a party for their next move in a negotiation,
+// given their memory and the outcome so far
+func NegotiationMoveFor(ctx context.Context, actor Actor, actorView ActorView, memory string, sofar NegotiationOutcome, round int, rounds int, turn Turn, backend LLMBackend) (NegotiationMove, error) {
+	if verbose {
+		log.Printf("[NegotiationMoveFor] Getting move of %s in round %d of %q", actor.Name, round, sofar.Topic)
+	}
+
+	actorJSON, err := json.Marshal(actor)
+	if err != nil {
+		return NegotiationMove{}, fmt.Errorf("failed to marshal actor: %v", err)
+	}
+	actorViewJSON, err := json.Marshal(actorView)
+	if err != nil {
+		return NegotiationMove{}, fmt.Errorf("failed to marshal actor view: %v", err)
+	}
+	transcriptJSON, err := json.Marshal(sofar.Transcript)
+	if err != nil {
+		return NegotiationMove{}, fmt.Errorf("failed to marshal transcript: %v", err)
+	}
+
+	onTable := "There is no proposal on the table yet."
+	if sofar.Terms != "" {
+		onTable = fmt.Sprintf("The proposal on the table, by %s, is: %s", sofar.ProposedBy, sofar.Terms)
+	}
+	prompt := fmt.Sprintf(`Given this actor: %s
+
+And their view of the world: %s
+
+The actor is negotiating about %s with %s. This is round %d of at most %d. The negotiation so far: %s
+
+%s
+
+What is the actor's next move, given their goals, powers, and what they know? Return a JSON object with:
+- party: the name of the actor
+- round: the round number
+- statement: what the actor says to the other parties
+- proposal: the terms the actor proposes, if they don't accept the proposal on the table; empty otherwise
+- accept: true if the actor accepts the proposal on the table as it stands
+- walk_away: true if the actor ends the negotiation without agreement
+
+The negotiation ends in agreement once every party accepts the same proposal. Only accept terms the actor would actually commit to.`,
+		string(actorJSON), string(actorViewJSON), sofar.Topic, strings.Join(otherParties(sofar.Parties, actor.Name), ", "), round, rounds, string(transcriptJSON), onTable)
+	if memory != "" {
+		prompt += fmt.Sprintf("\n\nThe actor's memory of the earlier turns they decided on, including their plans and commitments:\n%s", memory)
+	}
+	if turn.Date != "" {
+		prompt += fmt.Sprintf("\n\nThe current simulated date is %s.", turn.Date)
+	}
+
+	var move NegotiationMove
+	schema, err := jsonschema.GenerateSchemaForType(move)
+	if err != nil {
+		return NegotiationMove{}, fmt.Errorf("schema generation failed: %v", err)
+	}
+
+	openai_schema := openai.ChatCompletionResponseFormatJSONSchema{
+		Name:   "NegotiationMove",
+		Schema: schema,
+		Strict: true,
+	}
+
+	// A move is an actor's decision, so it uses the action model
+	openai_json, _, err := backend.FetchJSON(ctx, LLMRequest{Prompt: prompt, Model: models.Action, Schema: openai_schema, Stage: StageAction, Actor: actor.Name})
+	if err != nil {
+		return NegotiationMove{}, err
+	}
+
+	err = json.Unmarshal([]byte(openai_json), &move)
+	if err != nil {
+		return NegotiationMove{}, err
+	}
+	move.Party = actor.Name
+	move.Round = round
+
+	if verbose {
+		log.Printf("[NegotiationMoveFor] Move of %s determined", actor.Name)
+	}
+	return move, nil
+}
+
+func otherParties(parties []string, name string) []string {
+	var others []string
+	for _, party := range parties {
+		if party != name {
+			others = append(others, party)
+		}
+	}
+	return others
+}
+
+// findParties looks up the actors taking part in a negotiation. It fails if
+// one of them isn't among the actors.
+func findParties(negotiation Negotiation, actors Actors) ([]Actor, error) {
+	var parties []Actor
+	for _, name := range negotiation.Parties {
+		found := false
+		for _, actor := range actors.Actors {
+			if strings.EqualFold(strings.TrimSpace(name), actor.Name) {
+				parties = append(parties, actor)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("party %q is not one of the actors", name)
+		}
+	}
+	return parties, nil
+}
+
+// warnUnmatchedNegotiations tells the user about negotiations that will be
+// skipped because a party isn't among the simulation's actors, e.g. because
+// the generated actors are named differently. Otherwise that only shows up
+// in the simulation's log.
+func warnUnmatchedNegotiations(ctx context.Context, negotiations []Negotiation, actors Actors, logger SimLogger) {
+	for _, negotiation := range negotiations {
+		_, err := findParties(negotiation, actors)
+		if err == nil {
+			continue
+		}
+		warning := fmt.Sprintf("Warning: negotiation %q will be skipped: %v", negotiation.Topic, err)
+		if simulation := simulationKeyOf(ctx); simulation != "" {
+			warning = simulation + ": " + warning
+		}
+		fmt.Println(warning)
+		if _, console := logger.(*ConsoleLogger); !console {
+			logger.Println(warning)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// scriptedMoves answers negotiation moves from a script per actor, in order,
+// repeating the last move once the script runs out
+func scriptedMoves(script map[string][]string) func(req LLMRequest) (string, error) {
+	var mu sync.Mutex
+	made := make(map[string]int)
+	return func(req LLMRequest) (string, error) {
+		if req.Schema.Name != "NegotiationMove" {
+			return defaultFakeResponses[req.Schema.Name][0], nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		moves := script[req.Actor]
+		i := made[req.Actor]
+		if i >= len(moves) {
+			i = len(moves) - 1
+		}
+		made[req.Actor]++
+		return moves[i], nil
+	}
+}
+
+func move(proposal string, accept, walkAway bool) string {
+	return fmt.Sprintf(`{"party": "?", "round": 0, "statement": "s", "proposal": %q, "accept": %v, "walk_away": %v}`, proposal, accept, walkAway)
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name       string
+		script     map[string][]string
+		wantStatus string
+		wantTerms  string
+		wantRounds int
+		wantMoves  int
+	}{
+		{
+			name:       "agreement",
+			script:     map[string][]string{"Actor 1": {move("10% tariff", false, false)}, "Actor 2": {move("", true, false)}},
+			wantStatus: NegotiationAgreement, wantTerms: "10% tariff", wantRounds: 1, wantMoves: 2,
+		},
+		{
+			// Actor 2's counter-offer needs Actor 1 to accept it again
+			name: "counter-proposal",
+			script: map[string][]string{
+				"Actor 1": {move("10% tariff", false, false), move("", true, false)},
+				"Actor 2": {move("5% tariff", false, false)},
+			},
+			wantStatus: NegotiationAgreement, wantTerms: "5% tariff", wantRounds: 2, wantMoves: 3,
+		},
+		{
+			name:       "breakdown",
+			script:     map[string][]string{"Actor 1": {move("10% tariff", false, false)}, "Actor 2": {move("", false, true)}},
+			wantStatus: NegotiationBreakdown, wantTerms: "10% tariff", wantRounds: 1, wantMoves: 2,
+		},
+		{
+			// Accepting when nothing is on the table agrees to nothing
+			name:       "rounds run out",
+			script:     map[string][]string{"Actor 1": {move("", true, false)}, "Actor 2": {move("", true, false)}},
+			wantStatus: NegotiationNoAgreement, wantTerms: "", wantRounds: 3, wantMoves: 6,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := NewFakeBackend()
+			fake.Handler = scriptedMoves(tt.script)
+			negotiation := Negotiation{Topic: "tariffs", Parties: []string{"Actor 1", "Actor 2"}, MaxRounds: 3}
+			outcome, err := Negotiate(context.Background(), negotiation, makeActors(2).Actors, nil, nil, Turn{Number: 1}, fake, nil)
+			if err != nil {
+				t.Fatalf("Negotiate returned error: %v", err)
+			}
+			if outcome.Status != tt.wantStatus || outcome.Terms != tt.wantTerms || outcome.Rounds != tt.wantRounds || len(outcome.Transcript) != tt.wantMoves {
+				t.Errorf("outcome = %+v, want %s on %q after %d rounds and %d moves", outcome, tt.wantStatus, tt.wantTerms, tt.wantRounds, tt.wantMoves)
+			}
+			// Parties and rounds are set by the negotiation, not the model
+			for i, move := range outcome.Transcript {
+				if wantParty := fmt.Sprintf("Actor %d", i%2+1); move.Party != wantParty || move.Round != i/2+1 {
+					t.Errorf("move %d by %s in round %d, want %s in round %d", i, move.Party, move.Round, wantParty, i/2+1)
+				}
+			}
+		})
+	}
+}
+
+func TestNegotiationOutcomeEvent(t *testing.T) {
+	transcript := []NegotiationMove{{Party: "A"}, {Party: "B"}}
+	tests := []struct {
+		outcome NegotiationOutcome
+		want    string
+	}{
+		{NegotiationOutcome{Topic: "tariffs", Parties: []string{"A", "B"}, Status: NegotiationAgreement, Terms: "5%", Rounds: 2}, "Negotiation on tariffs between A, B: agreement reached after 2 rounds on: 5%"},
+		{NegotiationOutcome{Topic: "tariffs", Parties: []string{"A", "B"}, Status: NegotiationBreakdown, Rounds: 1, Transcript: transcript}, "Negotiation on tariffs between A, B broke down after 1 rounds when B walked away"},
+		{NegotiationOutcome{Topic: "tariffs", Parties: []string{"A", "B"}, Status: NegotiationNoAgreement, Terms: "5%", ProposedBy: "A", Rounds: 3}, "Negotiation on tariffs between A, B ended without agreement after 3 rounds; the last proposal, by A, was: 5%"},
+	}
+	for _, tt := range tests {
+		if got := tt.outcome.Event(); got != tt.want {
+			t.Errorf("Event() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestNegotiationInsideTurn(t *testing.T) {
+	saveDir := t.TempDir()
+	scenario := testScenario(2)
+	scenario.Actors = &Actors{Actors: makeActors(3).Actors}
+	scenario.Negotiations = []Negotiation{
+		{Topic: "tariffs", Parties: []string{"actor 1", "Actor 2"}, Turns: []int{2}},
+		{Topic: "nothing", Parties: []string{"Actor 1", "Nobody"}},
+	}
+	fake := NewFakeBackend()
+	if _, err := runSingleSimulation(context.Background(), scenario, fake, saveDir, discardLogger{}); err != nil {
+		t.Fatalf("runSingleSimulation returned error: %v", err)
+	}
+
+	// The default moves agree in the first round, on turn 2 only
+	if got := fake.CallCount("NegotiationMove"); got != 2 {
+		t.Errorf("%d negotiation moves, want 2", got)
+	}
+	event := "Negotiation on tariffs between Actor 1, Actor 2: agreement reached after 1 rounds on: fake terms"
+	var worldState WorldState
+	readJSONFile(t, filepath.Join(saveDir, "turn_2", "world_state.json"), &worldState)
+	if last := worldState.Events[len(worldState.Events)-1]; last != event {
+		t.Errorf("last event = %q, want the negotiation outcome", last)
+	}
+	var outcomes []NegotiationOutcome
+	readJSONFile(t, filepath.Join(saveDir, "turn_2", "negotiations.json"), &outcomes)
+	if len(outcomes) != 1 || outcomes[0].Status != NegotiationAgreement || len(outcomes[0].Transcript) != 2 {
+		t.Errorf("saved negotiations = %+v", outcomes)
+	}
+	if err := readJSON(filepath.Join(saveDir, "turn_1", "negotiations.json"), &outcomes); err == nil {
+		t.Errorf("negotiations.json written for a turn without negotiations")
+	}
+
+	// The parties act knowing the outcome, and the update is told about it
+	informed := make(map[string]bool)
+	for _, call := range fake.Calls() {
+		if strings.Contains(call.Prompt, event) {
+			informed[call.Actor+call.Stage] = true
+		}
+	}
+	for _, want := range []string{"Actor 1" + StageAction, "Actor 2" + StageAction, StageUpdate} {
+		if !informed[want] {
+			t.Errorf("%s was not told about the negotiation", want)
+		}
+	}
+	if informed["Actor 3"+StageAction] {
+		t.Errorf("Actor 3 was told about a negotiation it was not part of")
+	}
+}
+
+// recordingLogger keeps what is logged, one string per call
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func (l *recordingLogger) Println(v ...interface{}) {
+	l.lines = append(l.lines, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+}
+
+func TestWarnUnmatchedNegotiations(t *testing.T) {
+	negotiations := []Negotiation{
+		{Topic: "tariffs", Parties: []string{"actor 1", "Actor 2"}},
+		{Topic: "nothing", Parties: []string{"Actor 1", "Nobody"}},
+	}
+	logger := &recordingLogger{}
+	ctx := withSimulationKey(context.Background(), "simulation_1")
+	warnUnmatchedNegotiations(ctx, negotiations, makeActors(2), logger)
+
+	want := `simulation_1: Warning: negotiation "nothing" will be skipped: party "Nobody" is not one of the actors`
+	if len(logger.lines) != 1 || logger.lines[0] != want {
+		t.Errorf("logged %q, want only %q", logger.lines, want)
+	}
+}
+
+func TestUpdatePromptListsFieldsBeforeNegotiations(t *testing.T) {
+	fake := NewFakeBackend()
+	turn := Turn{Number: 1, Date: "2026-01-01", EndDate: "2026-02-01", Variables: []Variable{{Name: "Rate", Unit: "%"}}}
+	negotiations := []NegotiationOutcome{{Topic: "tariffs", Parties: []string{"A", "B"}, Status: NegotiationAgreement, Terms: "t", Rounds: 1}}
+	if _, err := UpdateWorldState(context.Background(), WorldState{}, nil, negotiations, turn, fake); err != nil {
+		t.Fatalf("UpdateWorldState returned error: %v", err)
+	}
+
+	prompt := fake.Calls()[0].Prompt
+	date, variables, concluded := strings.Index(prompt, "\n- date: 2026-02-01"), strings.Index(prompt, "\n- variables: "), strings.Index(prompt, "These negotiations were concluded")
+	if date < 0 || date > variables || variables < 0 || concluded < 0 {
+		t.Fatalf("prompt is missing a part:\n%s", prompt)
+	}
+	// One list, from its heading to the last field, before the negotiations
+	list := strings.Index(prompt, "Return the updated world state")
+	if strings.Contains(prompt[list:variables], "\n\n") || variables > concluded {
+		t.Errorf("the fields are not listed together before the negotiations:\n%s", prompt)
+	}
+}
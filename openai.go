@@ -2,7 +2,13 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"log"
+	"math/rand"
+	"net"
+	"net/http"
 	"time"
 	"fmt"
 
@@ -20,36 +26,154 @@ var GPT5 string = "gpt-5"
 var GPT5_2 string = "gpt-5.2"
 
 type OpenAIRequest struct {
-	prompt string
-	model  string
-	client *openai.Client
+	prompt  string
+	model   string
+	client  *openai.Client
+	limiter *RateLimiter
 }
 
-func retryWithBackoff(operation func() error, maxRetries int, verbose bool) error {
+// OpenAIBackend is an LLMBackend for the OpenAI API or any server speaking the
+// same protocol (llama.cpp, vLLM, Ollama, ...)
+type OpenAIBackend struct {
+	client  *openai.Client
+	limiter *RateLimiter
+}
+
+// NewOpenAIBackend creates a backend for the given API token. If baseURL is
+// empty, requests go to api.openai.com. Every attempt at every request waits
+// for the limiter, which may be nil.
+func NewOpenAIBackend(token string, baseURL string, limiter *RateLimiter) *OpenAIBackend {
+	config := openai.DefaultConfig(token)
+	if baseURL != "" {
+		config.BaseURL = baseURL
+	}
+	return &OpenAIBackend{client: openai.NewClientWithConfig(config), limiter: limiter}
+}
+
+func (b *OpenAIBackend) FetchJSON(ctx context.Context, req LLMRequest) (string, TokenUsage, error) {
+	return fetchOpenAIAnswerJSON(ctx, OpenAIRequest{prompt: req.Prompt, model: req.Model, client: b.client, limiter: b.limiter}, req.Schema, verbose)
+}
+
+// createChatCompletion sends one request once the limiter allows it, and
+// feeds the rate-limit headers of the response back to the limiter
+func createChatCompletion(ctx context.Context, req OpenAIRequest, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	release, err := req.limiter.Acquire(ctx, estimateTokens(req.prompt))
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	resp, err := req.client.CreateChatCompletion(ctx, request)
+	release(resp.Usage.TotalTokens)
+
+	if header := resp.Header(); header != nil && req.limiter != nil {
+		req.limiter.Observe(resp.GetRateLimitHeaders())
+		if err != nil {
+			if until, ok := retryAfter(header, time.Now()); ok {
+				req.limiter.PauseUntil(until)
+			}
+		}
+	}
+	return resp, err
+}
+
+// Number of attempts at each request before giving up. It is set from the
+// command line in main.
+var maxAttempts int = 5
+
+// Backoff before the second attempt; it doubles with every attempt after that
+var retryBaseDelay = time.Second
+
+var errEmptyChoices = errors.New("response has no choices")
+
+// malformedReplyError is a reply whose content isn't the JSON object asked for
+type malformedReplyError struct {
+	err error
+}
+
+func (e malformedReplyError) Error() string {
+	return fmt.Sprintf("response is not a JSON object: %v", e.err)
+}
+
+// isRetryable tells transient errors, which may go away on another attempt,
+// from ones that never will, such as an invalid API key, a rejected schema,
+// a prompt over the context length, or a request the client library rejects
+// before sending it, e.g. for a model that isn't a chat model
+func isRetryable(err error) bool {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.Code == "insufficient_quota" {
+			// Also a 429, but waiting won't help
+			return false
+		}
+		return retryableStatus(apiErr.HTTPStatusCode)
+	}
+	var requestErr *openai.RequestError
+	if errors.As(err, &requestErr) {
+		return retryableStatus(requestErr.HTTPStatusCode)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded) {
+		// Network errors and timeouts
+		return true
+	}
+	var malformed malformedReplyError
+	return errors.Is(err, errEmptyChoices) || errors.As(err, &malformed)
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusRequestTimeout || code == http.StatusConflict || code == http.StatusTooManyRequests || code >= 500
+}
+
+// backoffDelay is how long to wait after the given failed attempt: about
+// 1s, 2s, 4s, 8s, ..., randomized so that parallel requests don't retry in lockstep
+func backoffDelay(attempt int) time.Duration {
+	delay := retryBaseDelay * time.Duration(1<<uint(attempt-1))
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// retryWithBackoff retries operation until it succeeds, fails with an error
+// that isn't retryable, maxRetries attempts fail, or ctx is cancelled, which
+// also cuts short the wait between attempts
+func retryWithBackoff(ctx context.Context, operation func() error, maxRetries int, verbose bool) error {
 	var err error
 	for attempt := 1; attempt <= maxRetries; attempt++ {
 		err = operation()
 		if err == nil {
 			return nil
 		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !isRetryable(err) {
+			if verbose {
+				log.Printf("[RETRY] Attempt %d/%d failed with an error that won't go away: %v", attempt, maxRetries, err)
+			}
+			return err
+		}
 
 		if attempt < maxRetries {
-			backoffTime := time.Duration(1<<uint(attempt-1)) * time.Second // 1s, 2s, 4s, 8s, 16s
+			backoffTime := backoffDelay(attempt)
 			if verbose {
 				log.Printf("[RETRY] Attempt %d/%d failed: %v. Retrying in %v...", attempt, maxRetries, err, backoffTime)
 			}
-			time.Sleep(backoffTime)
+			timer := time.NewTimer(backoffTime)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
 		}
 	}
 	return fmt.Errorf("failed after %d attempts: %v", maxRetries, err)
 }
 
-func fetchOpenAIAnswer(req OpenAIRequest, verbose bool) (string, error) {
+func fetchOpenAIAnswer(ctx context.Context, req OpenAIRequest, verbose bool) (string, error) {
 	var result string
 
-	err := retryWithBackoff(func() error {
-		resp, err := req.client.CreateChatCompletion(
-			context.Background(),
+	err := retryWithBackoff(ctx, func() error {
+		resp, err := createChatCompletion(
+			ctx,
+			req,
 			openai.ChatCompletionRequest{
 				Model: req.model,
 				Messages: []openai.ChatCompletionMessage{
@@ -64,10 +188,13 @@ func fetchOpenAIAnswer(req OpenAIRequest, verbose bool) (string, error) {
 		if err != nil {
 			return err
 		}
+		if len(resp.Choices) == 0 {
+			return errEmptyChoices
+		}
 
 		result = resp.Choices[0].Message.Content
 		return nil
-	}, 5, verbose)
+	}, maxAttempts, verbose)
 
 	if err != nil {
 		if verbose {
@@ -79,17 +206,31 @@ func fetchOpenAIAnswer(req OpenAIRequest, verbose bool) (string, error) {
 	return result, nil
 }
 
-func fetchOpenAIAnswerJSON(req OpenAIRequest, schema openai.ChatCompletionResponseFormatJSONSchema, verbose bool) (string, error) {
+// tokenUsage converts the usage reported in an OpenAI response
+func tokenUsage(usage openai.Usage) TokenUsage {
+	converted := TokenUsage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+	}
+	if usage.CompletionTokensDetails != nil {
+		converted.ReasoningTokens = usage.CompletionTokensDetails.ReasoningTokens
+	}
+	return converted
+}
+
+func fetchOpenAIAnswerJSON(ctx context.Context, req OpenAIRequest, schema openai.ChatCompletionResponseFormatJSONSchema, verbose bool) (string, TokenUsage, error) {
 	if verbose {
 		log.Printf("[OPENAI] Making JSON request with model: %s", req.model)
 		log.Printf("[OPENAI] Prompt length: %d characters", len(req.prompt))
 	}
 
 	var result string
+	var usage TokenUsage
 
-	err := retryWithBackoff(func() error {
-		resp, err := req.client.CreateChatCompletion(
-			context.Background(),
+	err := retryWithBackoff(ctx, func() error {
+		resp, err := createChatCompletion(
+			ctx,
+			req,
 			openai.ChatCompletionRequest{
 				Model: req.model,
 				Messages: []openai.ChatCompletionMessage{
@@ -105,25 +246,39 @@ func fetchOpenAIAnswerJSON(req OpenAIRequest, schema openai.ChatCompletionRespon
 			},
 		)
 
+		// Replies that turn out to be unusable are billed all the same
+		used := tokenUsage(resp.Usage)
+		usage.PromptTokens += used.PromptTokens
+		usage.CompletionTokens += used.CompletionTokens
+		usage.ReasoningTokens += used.ReasoningTokens
+
 		if err != nil {
 			return err
 		}
+		if len(resp.Choices) == 0 {
+			return errEmptyChoices
+		}
 
 		result = resp.Choices[0].Message.Content
+		// Truncated or otherwise malformed output may be fine the next time
+		var object map[string]interface{}
+		if err := json.Unmarshal([]byte(result), &object); err != nil {
+			return malformedReplyError{err}
+		}
 		return nil
-	}, 5, verbose)
+	}, maxAttempts, verbose)
 
 	if err != nil {
 		if verbose {
 			log.Printf("[OPENAI] ChatCompletion error: %v\n", err)
 		}
-		return "", err
+		return "", usage, err
 	}
 
 	if verbose {
 		log.Printf("[OPENAI] ChatCompletion successful")
 		log.Printf("[OPENAI] Response content length: %d characters", len(result))
 	}
-	return result, nil
+	return result, usage, nil
 }
 
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeOpenAIReply struct {
+	status int // 0 drops the connection
+	body   string
+}
+
+// fakeOpenAIServer answers successive requests with replies, repeating the
+// last one, and counts the requests it gets
+func fakeOpenAIServer(t *testing.T, replies ...fakeOpenAIReply) (*httptest.Server, func() int) {
+	t.Helper()
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		reply := replies[len(replies)-1]
+		if requests < len(replies) {
+			reply = replies[requests]
+		}
+		requests++
+		mu.Unlock()
+		if reply.status == 0 {
+			// Drop the connection without answering
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(reply.status)
+		w.Write([]byte(reply.body))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+func chatReply(content string) fakeOpenAIReply {
+	return fakeOpenAIReply{http.StatusOK, `{"choices": [{"message": {"role": "assistant", "content": ` + content + `}}], "usage": {"prompt_tokens": 10, "completion_tokens": 5}}`}
+}
+
+func apiError(status int, code string) fakeOpenAIReply {
+	return fakeOpenAIReply{status, `{"error": {"message": "error ` + code + `", "type": "error", "code": "` + code + `"}}`}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	defer func(saved time.Duration) { retryBaseDelay = saved }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+	defer func(saved int) { maxAttempts = saved }(maxAttempts)
+
+	ok := chatReply(`"{\"answer\": \"a\"}"`)
+	tests := []struct {
+		name         string
+		maxAttempts  int
+		replies      []fakeOpenAIReply
+		wantRequests int
+		wantErr      string
+		wantTokens   int
+		model        string // GPT5_2 if empty
+	}{
+		{"success", 5, []fakeOpenAIReply{ok}, 1, "", 15, ""},
+		{"rate limited", 5, []fakeOpenAIReply{apiError(429, "rate_limit_exceeded"), ok}, 2, "", 15, ""},
+		{"server error", 5, []fakeOpenAIReply{apiError(500, "server_error"), apiError(503, "overloaded"), ok}, 3, "", 15, ""},
+		{"server error without JSON body", 5, []fakeOpenAIReply{{http.StatusBadGateway, "bad gateway"}, ok}, 2, "", 15, ""},
+		{"invalid API key", 5, []fakeOpenAIReply{apiError(401, "invalid_api_key")}, 1, "invalid_api_key", 0, ""},
+		{"context length exceeded", 5, []fakeOpenAIReply{apiError(400, "context_length_exceeded")}, 1, "context_length_exceeded", 0, ""},
+		{"quota exhausted", 5, []fakeOpenAIReply{apiError(429, "insufficient_quota")}, 1, "insufficient_quota", 0, ""},
+		{"no choices", 5, []fakeOpenAIReply{{http.StatusOK, `{"choices": []}`}, ok}, 2, "", 15, ""},
+		{"truncated JSON", 5, []fakeOpenAIReply{chatReply(`"{\"answer\": "`), ok}, 2, "", 30, ""},
+		{"out of attempts", 2, []fakeOpenAIReply{apiError(500, "server_error")}, 2, "failed after 2 attempts", 0, ""},
+		{"connection dropped", 5, []fakeOpenAIReply{{0, ""}, ok}, 2, "", 15, ""},
+		// Rejected by the client library, so never sent
+		{"not a chat model", 5, []fakeOpenAIReply{ok}, 0, "not supported with this method", 0, "text-davinci-003"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxAttempts = tt.maxAttempts
+			server, requests := fakeOpenAIServer(t, tt.replies...)
+			backend := NewOpenAIBackend("test", server.URL, nil)
+
+			model := tt.model
+			if model == "" {
+				model = GPT5_2
+			}
+			content, usage, err := backend.FetchJSON(context.Background(), LLMRequest{Prompt: "question", Model: model})
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("FetchJSON returned error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("error = %v, want it to mention %q", err, tt.wantErr)
+			}
+			if tt.wantErr == "" && content != `{"answer": "a"}` {
+				t.Errorf("content = %q", content)
+			}
+			if got := requests(); got != tt.wantRequests {
+				t.Errorf("%d requests, want %d", got, tt.wantRequests)
+			}
+			// Every reply is billed, usable or not
+			if usage.Total() != tt.wantTokens {
+				t.Errorf("usage = %d tokens, want %d", usage.Total(), tt.wantTokens)
+			}
+		})
+	}
+}
+
+func TestBackoffDelayHasJitter(t *testing.T) {
+	for attempt := 1; attempt <= 4; attempt++ {
+		base := retryBaseDelay * time.Duration(1<<uint(attempt-1))
+		seen := make(map[time.Duration]bool)
+		for i := 0; i < 20; i++ {
+			delay := backoffDelay(attempt)
+			if delay < base/2 || delay > base {
+				t.Fatalf("backoffDelay(%d) = %v, want between %v and %v", attempt, delay, base/2, base)
+			}
+			seen[delay] = true
+		}
+		if len(seen) == 1 {
+			t.Errorf("backoffDelay(%d) is always %v", attempt, base)
+		}
+	}
+}
@@ -0,0 +1,874 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	jsonschema "github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// Question types
+const (
+	QuestionBinary      = "binary"
+	QuestionNumeric     = "numeric"
+	QuestionCategorical = "categorical"
+	QuestionDate        = "date"
+)
+
+// Format of date answers
+const dateLayout = "2006-01-02"
+
+// Question is a question answered at the end of each simulation. In scenario
+// files a plain string is a binary (yes/no) question.
+type Question struct {
+	Text     string   `json:"text"`
+	Type     string   `json:"type,omitempty"`     // binary (default), numeric, categorical or date
+	Unit     string   `json:"unit,omitempty"`     // numeric
+	Min      *float64 `json:"min,omitempty"`      // numeric, optional lower bound
+	Max      *float64 `json:"max,omitempty"`      // numeric, optional upper bound
+	Options  []string `json:"options,omitempty"`  // categorical
+	Deadline string   `json:"deadline,omitempty"` // Optional, YYYY-MM-DD; only simulated events up to then count
+	Variable string   `json:"variable,omitempty"` // numeric; answered with this variable's final value
+}
+
+func (q *Question) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*q = Question{Text: text}
+		return nil
+	}
+	type plainQuestion Question
+	var plain plainQuestion
+	if err := json.Unmarshal(data, &plain); err != nil {
+		return err
+	}
+	*q = Question(plain)
+	return nil
+}
+
+func (q Question) MarshalJSON() ([]byte, error) {
+	if q.kind() == QuestionBinary && q.Deadline == "" {
+		return json.Marshal(q.Text)
+	}
+	type plainQuestion Question
+	return json.Marshal(plainQuestion(q))
+}
+
+// kind returns the question's type, defaulting to binary, or to numeric for
+// questions that read a variable
+func (q Question) kind() string {
+	if q.Type == "" && q.Variable != "" {
+		return QuestionNumeric
+	}
+	if q.Type == "" {
+		return QuestionBinary
+	}
+	return q.Type
+}
+
+// readingVariable returns the question with the unit and bounds of the
+// variable it reads, where it doesn't give its own
+func (q Question) readingVariable(variable Variable) Question {
+	q.Variable = variable.Name
+	if q.Unit == "" {
+		q.Unit = variable.Unit
+	}
+	if q.Min == nil {
+		q.Min = variable.Min
+	}
+	if q.Max == nil {
+		q.Max = variable.Max
+	}
+	return q
+}
+
+// validate checks that the question is fully specified for its type
+func (q Question) validate() error {
+	if strings.TrimSpace(q.Text) == "" {
+		return fmt.Errorf("empty question")
+	}
+	if q.Deadline != "" {
+		if _, err := time.Parse(dateLayout, q.Deadline); err != nil {
+			return fmt.Errorf("question %q has a deadline %q not formatted as YYYY-MM-DD", q.Text, q.Deadline)
+		}
+	}
+	if q.Variable != "" && q.kind() != QuestionNumeric {
+		return fmt.Errorf("question %q reads a variable but is not numeric", q.Text)
+	}
+	if q.Variable != "" && q.Deadline != "" {
+		return fmt.Errorf("question %q reads a variable, which is answered at the end of the simulation, so it can't have a deadline", q.Text)
+	}
+	switch q.kind() {
+	case QuestionBinary, QuestionDate:
+	case QuestionNumeric:
+		if q.Min != nil && q.Max != nil && *q.Min > *q.Max {
+			return fmt.Errorf("question %q has min greater than max", q.Text)
+		}
+	case QuestionCategorical:
+		if len(q.Options) < 2 {
+			return fmt.Errorf("categorical question %q needs at least two options", q.Text)
+		}
+	default:
+		return fmt.Errorf("question %q has unknown type %q", q.Text, q.Type)
+	}
+	return nil
+}
+
+// NumericAnswer is the model's answer to a numeric question
+type NumericAnswer struct {
+	Answer string  `json:"answer"`
+	Value  float64 `json:"value"`
+}
+
+// CategoricalAnswer is the model's answer to a categorical question
+type CategoricalAnswer struct {
+	Answer string `json:"answer"`
+	Choice string `json:"choice"`
+}
+
+// DateAnswer is the model's answer to a date question
+type DateAnswer struct {
+	Answer string `json:"answer"`
+	Date   string `json:"date"`
+}
+
+// invalidAnswerError is an answer that doesn't fit its question, such as an
+// option that isn't on the list; asking again may give a valid one
+type invalidAnswerError struct {
+	reason string
+}
+
+func (e invalidAnswerError) Error() string {
+	return e.reason
+}
+
+// Times a question is asked before an invalid answer is given up on
+const answerAttempts = 2
+
+// askUntilValid calls ask, again if its answer is invalid, up to
+// answerAttempts times. An answer that is still invalid comes with an
+// invalidAnswerError.
+func askUntilValid(ask func() (string, string, error)) (string, string, error) {
+	for attempt := 1; ; attempt++ {
+		answer, value, err := ask()
+		var invalid invalidAnswerError
+		if !errors.As(err, &invalid) || attempt == answerAttempts {
+			return answer, value, err
+		}
+	}
+}
+
+// NumericSummary describes the distribution of answers to a numeric question
+type NumericSummary struct {
+	Unit      string         `json:"unit,omitempty"`
+	Mean      float64        `json:"mean"`
+	Min       float64        `json:"min"`
+	P10       float64        `json:"p10"`
+	P25       float64        `json:"p25"`
+	Median    float64        `json:"median"`
+	P75       float64        `json:"p75"`
+	P90       float64        `json:"p90"`
+	Max       float64        `json:"max"`
+	Histogram []HistogramBin `json:"histogram"`
+}
+
+// OptionCount is how often one option of a categorical question was chosen
+type OptionCount struct {
+	Option     string  `json:"option"`
+	Count      int     `json:"count"`
+	Percentage float64 `json:"percentage"`
+}
+
+// MonthCount is how many date answers fall in one month (YYYY-MM)
+type MonthCount struct {
+	Month string `json:"month"`
+	Count int    `json:"count"`
+}
+
+// DateSummary describes the distribution of answers to a date question
+type DateSummary struct {
+	Earliest  string       `json:"earliest"`
+	P10       string       `json:"p10"`
+	Median    string       `json:"median"`
+	P90       string       `json:"p90"`
+	Latest    string       `json:"latest"`
+	Histogram []MonthCount `json:"histogram"`
+}
+
+// Confidence level of the intervals on yes percentages, set from --confidence
+var confidenceLevel float64 = 0.95
+
+// Width, in percentage points, that yes intervals should narrow to, set from
+// --target-width
+var targetWidth float64 = 10
+
+// QuestionAggregate summarizes the answers to one question across simulations.
+// The yes/no fields only apply to binary questions; other types fill in
+// numeric, options or dates instead. Categorical and date questions count
+// the simulations with no valid answer as unresolved.
+type QuestionAggregate struct {
+	Question             string          `json:"question"`
+	Type                 string          `json:"type"`
+	YesCount             int             `json:"yes_count"`
+	NoCount              int             `json:"no_count"`
+	UnresolvedCount      int             `json:"unresolved_count"`
+	YesPercentage        float64         `json:"yes_percentage"`                  // Of the simulations that resolved the question
+	UnresolvedPercentage float64         `json:"unresolved_percentage"`           // Of all simulations answered
+	MeanProbability      *float64        `json:"mean_probability,omitempty"`      // Of yes, over all simulations answered
+	YesInterval          *Interval       `json:"yes_interval,omitempty"`          // Wilson score interval of yes_percentage
+	YesCredibleInterval  *Interval       `json:"yes_credible_interval,omitempty"` // Beta (Jeffreys prior) credible interval of yes_percentage
+	SimulationsNeeded    int             `json:"simulations_needed,omitempty"`    // Estimated further simulations for yes_interval to be at most --target-width wide
+	Numeric              *NumericSummary `json:"numeric,omitempty"`
+	Options              []OptionCount   `json:"options,omitempty"`
+	Dates                *DateSummary    `json:"dates,omitempty"`
+}
+
+// SimulationFailure records a simulation that could not be completed
+type SimulationFailure struct {
+	Simulation  int    `json:"simulation"`
+	Error       string `json:"error"`
+	Stopped     bool   `json:"stopped,omitempty"`     // Stopped, or never started, because the budget ran out
+	Interrupted bool   `json:"interrupted,omitempty"` // Stopped, or never started, because the run was interrupted
+}
+
+// AggregateResult is what gets saved to aggregate_results.json
+type AggregateResult struct {
+	Scenario          string               `json:"scenario"`
+	Turns             int                  `json:"turns"`
+	Total             int                  `json:"total"`
+	Successful        int                  `json:"successful"`
+	Failed            int                  `json:"failed"`
+	Stopped           int                  `json:"stopped,omitempty"`     // Stopped by the budget, listed in failures but not counted as failed
+	Interrupted       int                  `json:"interrupted,omitempty"` // Interrupted by Ctrl-C, listed in failures but not counted as failed
+	Failures          []SimulationFailure  `json:"failures,omitempty"`
+	Questions         []QuestionAggregate  `json:"questions"`           // Over successful simulations only
+	Usage             UsageReport          `json:"usage"`               // Summed over all simulations, failed ones included
+	Variables         []VariableSeries     `json:"variables,omitempty"` // Turn by turn, over successful simulations only
+	Shocks            []ShockBreakdown     `json:"shocks,omitempty"`    // Answers split by whether each shock fired, over successful simulations only
+	IndividualResults [][]SimulationResult `json:"individual_results"`  // Per simulation, one result per question; null for failed simulations
+}
+
+// AllQuestions returns the scenario's question followed by any extra questions
+func (s Scenario) AllQuestions() []Question {
+	var questions []Question
+	if s.Question != "" {
+		questions = append(questions, Question{Text: s.Question})
+	}
+	return append(questions, s.Questions...)
+}
+
+// answerPrompt is the part of the prompt shared by all question types. With a
+// calendar, it says when the simulation ends and what the question's deadline is.
+func answerPrompt(question Question, worldState WorldState, allActions [][]ActorAction) (string, error) {
+	worldStateJSON, err := json.Marshal(worldState)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal world state: %v", err)
+	}
+
+	allActionsJSON, err := json.Marshal(allActions)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal all actions: %v", err)
+	}
+
+	prompt := fmt.Sprintf(`Given this final world state: %s
+
+And this history of all actions taken across turns: %s
+
+Please answer this question: %s`, string(worldStateJSON), string(allActionsJSON), question.Text)
+	if worldState.Date != "" {
+		prompt += fmt.Sprintf("\n\nThe simulation ends on %s. Events in the world state start with the simulated date on which they happened.", worldState.Date)
+	}
+	if question.Deadline != "" {
+		prompt += fmt.Sprintf("\n\nThe question is about what happens by %s: only count events on or before that date.", question.Deadline)
+		if worldState.Date != "" && worldState.Date < question.Deadline {
+			prompt += fmt.Sprintf(" The simulation ends before the deadline, so what happens between %s and %s is not known; take that into account.", worldState.Date, question.Deadline)
+		}
+	}
+	return prompt, nil
+}
+
+// AnswerNumericQuestion answers a question whose answer is a number, such as a rate or a price
+func AnswerNumericQuestion(ctx context.Context, question Question, worldState WorldState, allActions [][]ActorAction, backend LLMBackend) (string, float64, error) {
+	if verbose {
+		log.Printf("[AnswerNumericQuestion] Answering question: %s", question.Text)
+	}
+
+	prompt, err := answerPrompt(question, worldState, allActions)
+	if err != nil {
+		return "", 0, err
+	}
+	constraints := ""
+	if question.Unit != "" {
+		constraints += fmt.Sprintf("\n- the value is expressed in %s", question.Unit)
+	}
+	if question.Min != nil {
+		constraints += fmt.Sprintf("\n- the value is at least %v", *question.Min)
+	}
+	if question.Max != nil {
+		constraints += fmt.Sprintf("\n- the value is at most %v", *question.Max)
+	}
+	prompt += fmt.Sprintf(`
+
+Provide a JSON response with:
+- answer: a clear detailed answer, referencing specific events and actions from the simulation
+- value: your single best estimate of the number asked for%s`, constraints)
+
+	var numericAnswer NumericAnswer
+	schema, err := jsonschema.GenerateSchemaForType(numericAnswer)
+	if err != nil {
+		return "", 0, fmt.Errorf("schema generation failed: %v", err)
+	}
+
+	openai_schema := openai.ChatCompletionResponseFormatJSONSchema{
+		Name:   "NumericAnswer",
+		Schema: schema,
+		Strict: true,
+	}
+
+	openai_json, _, err := backend.FetchJSON(ctx, LLMRequest{Prompt: prompt, Model: models.Answer, Schema: openai_schema, Stage: StageAnswer})
+	if err != nil {
+		return "", 0, err
+	}
+
+	err = json.Unmarshal([]byte(openai_json), &numericAnswer)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// The schema can't express bounds, so enforce them here
+	value := numericAnswer.Value
+	if question.Min != nil && value < *question.Min {
+		value = *question.Min
+	}
+	if question.Max != nil && value > *question.Max {
+		value = *question.Max
+	}
+
+	if verbose {
+		log.Printf("[AnswerNumericQuestion] Question answered successfully")
+	}
+	return numericAnswer.Answer, value, nil
+}
+
+// AnswerCategoricalQuestion answers a question by picking one of a fixed list of options
+func AnswerCategoricalQuestion(ctx context.Context, question Question, worldState WorldState, allActions [][]ActorAction, backend LLMBackend) (string, string, error) {
+	if verbose {
+		log.Printf("[AnswerCategoricalQuestion] Answering question: %s", question.Text)
+	}
+
+	prompt, err := answerPrompt(question, worldState, allActions)
+	if err != nil {
+		return "", "", err
+	}
+	prompt += fmt.Sprintf(`
+
+Provide a JSON response with:
+- answer: a clear detailed answer, referencing specific events and actions from the simulation
+- choice: exactly one of these options: %s`, strings.Join(question.Options, "; "))
+
+	var categoricalAnswer CategoricalAnswer
+	schema, err := jsonschema.GenerateSchemaForType(categoricalAnswer)
+	if err != nil {
+		return "", "", fmt.Errorf("schema generation failed: %v", err)
+	}
+	choice := schema.Properties["choice"]
+	choice.Enum = question.Options
+	schema.Properties["choice"] = choice
+
+	openai_schema := openai.ChatCompletionResponseFormatJSONSchema{
+		Name:   "CategoricalAnswer",
+		Schema: schema,
+		Strict: true,
+	}
+
+	openai_json, _, err := backend.FetchJSON(ctx, LLMRequest{Prompt: prompt, Model: models.Answer, Schema: openai_schema, Stage: StageAnswer})
+	if err != nil {
+		return "", "", err
+	}
+
+	err = json.Unmarshal([]byte(openai_json), &categoricalAnswer)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Not every OpenAI-compatible server enforces enums
+	valid := false
+	for _, option := range question.Options {
+		if categoricalAnswer.Choice == option {
+			valid = true
+		}
+	}
+	if !valid {
+		return categoricalAnswer.Answer, "", invalidAnswerError{fmt.Sprintf("choice %q is not one of the options", categoricalAnswer.Choice)}
+	}
+
+	if verbose {
+		log.Printf("[AnswerCategoricalQuestion] Question answered successfully")
+	}
+	return categoricalAnswer.Answer, categoricalAnswer.Choice, nil
+}
+
+// AnswerDateQuestion answers a question whose answer is a calendar date
+func AnswerDateQuestion(ctx context.Context, question Question, worldState WorldState, allActions [][]ActorAction, backend LLMBackend) (string, string, error) {
+	if verbose {
+		log.Printf("[AnswerDateQuestion] Answering question: %s", question.Text)
+	}
+
+	prompt, err := answerPrompt(question, worldState, allActions)
+	if err != nil {
+		return "", "", err
+	}
+	prompt += `
+
+Provide a JSON response with:
+- answer: a clear detailed answer, referencing specific events and actions from the simulation
+- date: your single best estimate of the date asked for, formatted as YYYY-MM-DD`
+
+	var dateAnswer DateAnswer
+	schema, err := jsonschema.GenerateSchemaForType(dateAnswer)
+	if err != nil {
+		return "", "", fmt.Errorf("schema generation failed: %v", err)
+	}
+
+	openai_schema := openai.ChatCompletionResponseFormatJSONSchema{
+		Name:   "DateAnswer",
+		Schema: schema,
+		Strict: true,
+	}
+
+	openai_json, _, err := backend.FetchJSON(ctx, LLMRequest{Prompt: prompt, Model: models.Answer, Schema: openai_schema, Stage: StageAnswer})
+	if err != nil {
+		return "", "", err
+	}
+
+	err = json.Unmarshal([]byte(openai_json), &dateAnswer)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := time.Parse(dateLayout, dateAnswer.Date); err != nil {
+		return dateAnswer.Answer, "", invalidAnswerError{fmt.Sprintf("date %q is not formatted as YYYY-MM-DD", dateAnswer.Date)}
+	}
+
+	if verbose {
+		log.Printf("[AnswerDateQuestion] Question answered successfully")
+	}
+	return dateAnswer.Answer, dateAnswer.Date, nil
+}
+
+// answerQuestion answers a question of any type
+func answerQuestion(ctx context.Context, question Question, worldState WorldState, allActions [][]ActorAction, backend LLMBackend) (SimulationResult, error) {
+	result := SimulationResult{Question: question.Text, Type: question.kind()}
+	switch question.kind() {
+	case QuestionNumeric:
+		// Questions that read a variable don't need the model, unless the
+		// variable is missing, e.g. from a world state saved before it was declared
+		if value, ok := findValue(worldState.Variables, question.Variable); ok && question.Variable != "" {
+			result.Answer = fmt.Sprintf("%s is %g at the end of the simulation: %s", value.Name, value.Value, value.Justification)
+			result.Value = &value.Value
+			break
+		}
+		answer, value, err := AnswerNumericQuestion(ctx, question, worldState, allActions, backend)
+		if err != nil {
+			return SimulationResult{}, err
+		}
+		result.Answer = answer
+		result.Value = &value
+	case QuestionCategorical:
+		answer, choice, err := askUntilValid(func() (string, string, error) {
+			return AnswerCategoricalQuestion(ctx, question, worldState, allActions, backend)
+		})
+		if err := invalidAnswer(&result, answer, err); err != nil {
+			return SimulationResult{}, err
+		}
+		result.Choice = choice
+	case QuestionDate:
+		answer, date, err := askUntilValid(func() (string, string, error) {
+			return AnswerDateQuestion(ctx, question, worldState, allActions, backend)
+		})
+		if err := invalidAnswer(&result, answer, err); err != nil {
+			return SimulationResult{}, err
+		}
+		result.Date = date
+	default:
+		answer, err := AnswerSummarizationQuestion(ctx, question, worldState, allActions, backend)
+		if err != nil {
+			return SimulationResult{}, err
+		}
+		result.Answer = answer.Answer
+		result.YesNo = answer.YesNo
+		result.Unresolved = answer.Unresolved
+		result.Probability = answer.Probability
+	}
+	return result, nil
+}
+
+// invalidAnswer sets the result's answer, marking it unresolved if the model
+// never gave a valid one, rather than failing a simulation whose turns have
+// all been run. It returns any other error.
+func invalidAnswer(result *SimulationResult, answer string, err error) error {
+	var invalid invalidAnswerError
+	if errors.As(err, &invalid) {
+		result.Answer = fmt.Sprintf("%s (no valid answer after %d attempts: %v)", answer, answerAttempts, err)
+		result.Unresolved = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	result.Answer = answer
+	return nil
+}
+
+// answerQuestions answers every question against the same final world state
+// and action history, in parallel. Results are in the same order as questions.
+func answerQuestions(ctx context.Context, questions []Question, worldState WorldState, allActions [][]ActorAction, backend LLMBackend) ([]SimulationResult, error) {
+	type answerResult struct {
+		result SimulationResult
+		err    error
+		index  int
+	}
+
+	// Stop the other questions as soon as one fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	answers := make(chan answerResult, len(questions))
+	var wg sync.WaitGroup
+
+	for i, question := range questions {
+		wg.Add(1)
+		go func(idx int, q Question) {
+			defer wg.Done()
+
+			result, err := answerQuestion(ctx, q, worldState, allActions, backend)
+			if err != nil {
+				answers <- answerResult{
+					err:   fmt.Errorf("failed to answer %q: %v", q.Text, err),
+					index: idx,
+				}
+				return
+			}
+
+			answers <- answerResult{
+				result: result,
+				index:  idx,
+			}
+		}(i, question)
+	}
+
+	// Wait for all goroutines to complete
+	go func() {
+		wg.Wait()
+		close(answers)
+	}()
+
+	// Collect results
+	results := make([]SimulationResult, len(questions))
+	for answer := range answers {
+		if answer.err != nil {
+			return nil, answer.err
+		}
+		results[answer.index] = answer.result
+	}
+	return results, nil
+}
+
+// Outcome is a short rendering of the result's answer, e.g. "true", "unresolved", "0.75" or "2026-03-31"
+func (r SimulationResult) Outcome() string {
+	if r.Unresolved {
+		return "unresolved"
+	}
+	switch r.Type {
+	case QuestionNumeric:
+		if r.Value == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*r.Value, 'g', -1, 64)
+	case QuestionCategorical:
+		return r.Choice
+	case QuestionDate:
+		return r.Date
+	default:
+		return strconv.FormatBool(r.YesNo)
+	}
+}
+
+// probability returns the probability of yes of a binary result. Results
+// saved before answers had probabilities count as certain.
+func (r SimulationResult) probability() float64 {
+	if r.Probability != nil {
+		return *r.Probability
+	}
+	if r.YesNo {
+		return 1
+	}
+	return 0
+}
+
+// aggregateAnswers summarizes the answers to each question across simulations.
+// Each element of results holds one simulation's answers, in question order.
+func aggregateAnswers(questions []Question, results [][]SimulationResult) []QuestionAggregate {
+	aggregates := make([]QuestionAggregate, len(questions))
+	for i, question := range questions {
+		var answers []SimulationResult
+		for _, simulationResults := range results {
+			if i < len(simulationResults) {
+				answers = append(answers, simulationResults[i])
+			}
+		}
+
+		aggregate := QuestionAggregate{Question: question.Text, Type: question.kind()}
+		switch question.kind() {
+		case QuestionNumeric:
+			aggregate.Numeric = summarizeNumeric(question, answers)
+		case QuestionCategorical:
+			aggregate.Options = countOptions(question, answers)
+			countUnresolved(&aggregate, answers)
+		case QuestionDate:
+			aggregate.Dates = summarizeDates(answers)
+			countUnresolved(&aggregate, answers)
+		default:
+			var probabilities []float64
+			for _, answer := range answers {
+				probabilities = append(probabilities, answer.probability())
+				if answer.Unresolved {
+					aggregate.UnresolvedCount++
+				} else if answer.YesNo {
+					aggregate.YesCount++
+				} else {
+					aggregate.NoCount++
+				}
+			}
+			// The yes rate is over the simulations that settled the question
+			if total := aggregate.YesCount + aggregate.NoCount; total > 0 {
+				aggregate.YesPercentage = float64(aggregate.YesCount) / float64(total) * 100
+			}
+			if len(answers) > 0 {
+				aggregate.UnresolvedPercentage = float64(aggregate.UnresolvedCount) / float64(len(answers)) * 100
+				meanProbability := mean(probabilities)
+				aggregate.MeanProbability = &meanProbability
+			}
+			addYesIntervals(&aggregate)
+		}
+		aggregates[i] = aggregate
+	}
+	return aggregates
+}
+
+// countUnresolved counts the answers of a categorical or date question that
+// had no valid option or date
+func countUnresolved(aggregate *QuestionAggregate, answers []SimulationResult) {
+	for _, answer := range answers {
+		if answer.Unresolved {
+			aggregate.UnresolvedCount++
+		}
+	}
+	if len(answers) > 0 {
+		aggregate.UnresolvedPercentage = float64(aggregate.UnresolvedCount) / float64(len(answers)) * 100
+	}
+}
+
+// addYesIntervals sets the intervals of a binary question's yes percentage,
+// and estimates how many more simulations would narrow them to targetWidth
+func addYesIntervals(aggregate *QuestionAggregate) {
+	resolved := aggregate.YesCount + aggregate.NoCount
+	if resolved == 0 {
+		return
+	}
+	wilson := wilsonInterval(float64(aggregate.YesCount), float64(resolved), confidenceLevel)
+	beta := betaInterval(aggregate.YesCount, resolved, confidenceLevel)
+	aggregate.YesInterval = &Interval{Low: wilson.Low * 100, High: wilson.High * 100, Level: confidenceLevel}
+	aggregate.YesCredibleInterval = &Interval{Low: beta.Low * 100, High: beta.High * 100, Level: confidenceLevel}
+
+	if targetWidth <= 0 || aggregate.YesInterval.Width() <= targetWidth {
+		return
+	}
+	// Assume the yes rate and share of unresolved simulations stay the same
+	p := float64(aggregate.YesCount) / float64(resolved)
+	moreResolved := trialsForWidth(p, resolved, targetWidth/100, confidenceLevel) - resolved
+	resolvedShare := float64(resolved) / float64(resolved+aggregate.UnresolvedCount)
+	aggregate.SimulationsNeeded = int(math.Ceil(float64(moreResolved) / resolvedShare))
+}
+
+// simulationsNeeded returns the most further simulations any binary question
+// needs for its yes interval to narrow to targetWidth, or 0 if none needs more
+func simulationsNeeded(aggregates []QuestionAggregate) int {
+	needed := 0
+	for _, aggregate := range aggregates {
+		if aggregate.SimulationsNeeded > needed {
+			needed = aggregate.SimulationsNeeded
+		}
+	}
+	return needed
+}
+
+// unresolvedQuestions returns the binary questions that no simulation has
+// resolved. They have no interval, so they can't have converged.
+func unresolvedQuestions(aggregates []QuestionAggregate) []string {
+	var unresolved []string
+	for _, aggregate := range aggregates {
+		if aggregate.Type == QuestionBinary && aggregate.YesInterval == nil {
+			unresolved = append(unresolved, aggregate.Question)
+		}
+	}
+	return unresolved
+}
+
+// Number of bins in numeric histograms
+const numericHistogramBins = 10
+
+func summarizeNumeric(question Question, answers []SimulationResult) *NumericSummary {
+	var values []float64
+	for _, answer := range answers {
+		if answer.Value != nil {
+			values = append(values, *answer.Value)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	sorted := sortedCopy(values)
+	low, high := sorted[0], sorted[len(sorted)-1]
+	// Bin over the question's range when it has one, so histograms of
+	// different runs of the same question line up
+	if question.Min != nil && question.Max != nil {
+		low, high = math.Min(low, *question.Min), math.Max(high, *question.Max)
+	}
+
+	return &NumericSummary{
+		Unit:      question.Unit,
+		Mean:      mean(values),
+		Min:       sorted[0],
+		P10:       quantile(sorted, 0.10),
+		P25:       quantile(sorted, 0.25),
+		Median:    quantile(sorted, 0.50),
+		P75:       quantile(sorted, 0.75),
+		P90:       quantile(sorted, 0.90),
+		Max:       sorted[len(sorted)-1],
+		Histogram: histogram(values, low, high, numericHistogramBins),
+	}
+}
+
+func countOptions(question Question, answers []SimulationResult) []OptionCount {
+	counts := make([]OptionCount, len(question.Options))
+	for i, option := range question.Options {
+		counts[i].Option = option
+		for _, answer := range answers {
+			if answer.Choice == option {
+				counts[i].Count++
+			}
+		}
+		if len(answers) > 0 {
+			counts[i].Percentage = float64(counts[i].Count) / float64(len(answers)) * 100
+		}
+	}
+	return counts
+}
+
+func summarizeDates(answers []SimulationResult) *DateSummary {
+	// Work in days since the epoch, so the numeric quantiles can be reused
+	var days []float64
+	monthCounts := make(map[string]int)
+	var months []string
+	for _, answer := range answers {
+		date, err := time.Parse(dateLayout, answer.Date)
+		if err != nil {
+			continue
+		}
+		days = append(days, float64(date.Unix()/86400))
+		month := date.Format("2006-01")
+		if monthCounts[month] == 0 {
+			months = append(months, month)
+		}
+		monthCounts[month]++
+	}
+	if len(days) == 0 {
+		return nil
+	}
+
+	sorted := sortedCopy(days)
+	toDate := func(day float64) string {
+		return time.Unix(int64(math.Round(day))*86400, 0).UTC().Format(dateLayout)
+	}
+
+	// Months sort chronologically as strings
+	sort.Strings(months)
+	monthHistogram := make([]MonthCount, len(months))
+	for i, month := range months {
+		monthHistogram[i] = MonthCount{Month: month, Count: monthCounts[month]}
+	}
+
+	return &DateSummary{
+		Earliest:  toDate(sorted[0]),
+		P10:       toDate(quantile(sorted, 0.10)),
+		Median:    toDate(quantile(sorted, 0.50)),
+		P90:       toDate(quantile(sorted, 0.90)),
+		Latest:    toDate(sorted[len(sorted)-1]),
+		Histogram: monthHistogram,
+	}
+}
+
+// printQuestionAggregate writes the summary of one question to the console
+func printQuestionAggregate(aggregate QuestionAggregate) {
+	fmt.Printf("\nQuestion: %s\n", aggregate.Question)
+	switch aggregate.Type {
+	case QuestionNumeric:
+		if aggregate.Numeric == nil {
+			fmt.Printf("No valid answers\n")
+			return
+		}
+		n := aggregate.Numeric
+		fmt.Printf("Mean: %g %s\n", n.Mean, n.Unit)
+		fmt.Printf("Median: %g %s (10th-90th percentile: %g to %g)\n", n.Median, n.Unit, n.P10, n.P90)
+		fmt.Printf("Range: %g to %g\n", n.Min, n.Max)
+		for _, bin := range n.Histogram {
+			fmt.Printf("  [%g, %g): %s %d\n", bin.Low, bin.High, strings.Repeat("#", bin.Count), bin.Count)
+		}
+	case QuestionCategorical:
+		for _, option := range aggregate.Options {
+			fmt.Printf("%s: %d (%.1f%%)\n", option.Option, option.Count, option.Percentage)
+		}
+		if aggregate.UnresolvedCount > 0 {
+			fmt.Printf("No valid answer: %d (%.1f%%)\n", aggregate.UnresolvedCount, aggregate.UnresolvedPercentage)
+		}
+	case QuestionDate:
+		if aggregate.Dates == nil {
+			fmt.Printf("No valid answers\n")
+			return
+		}
+		if aggregate.UnresolvedCount > 0 {
+			fmt.Printf("No valid answer: %d (%.1f%%)\n", aggregate.UnresolvedCount, aggregate.UnresolvedPercentage)
+		}
+		d := aggregate.Dates
+		fmt.Printf("Median: %s (10th-90th percentile: %s to %s)\n", d.Median, d.P10, d.P90)
+		fmt.Printf("Range: %s to %s\n", d.Earliest, d.Latest)
+		for _, month := range d.Histogram {
+			fmt.Printf("  %s: %s %d\n", month.Month, strings.Repeat("#", month.Count), month.Count)
+		}
+	default:
+		fmt.Printf("Yes count: %d\n", aggregate.YesCount)
+		fmt.Printf("No count: %d\n", aggregate.NoCount)
+		fmt.Printf("Unresolved count: %d (%.1f%%)\n", aggregate.UnresolvedCount, aggregate.UnresolvedPercentage)
+		fmt.Printf("Yes percentage: %.1f%% of resolved\n", aggregate.YesPercentage)
+		if aggregate.YesInterval != nil {
+			fmt.Printf("%g%% interval: %.1f%% to %.1f%% (Wilson), %.1f%% to %.1f%% (Beta)\n", aggregate.YesInterval.Level*100, aggregate.YesInterval.Low, aggregate.YesInterval.High, aggregate.YesCredibleInterval.Low, aggregate.YesCredibleInterval.High)
+		}
+		if aggregate.SimulationsNeeded > 0 {
+			fmt.Printf("About %d more simulations would narrow it to %g points\n", aggregate.SimulationsNeeded, targetWidth)
+		}
+		if aggregate.MeanProbability != nil {
+			fmt.Printf("Mean probability: %.1f%%\n", *aggregate.MeanProbability*100)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// answerByQuestionHandler answers yes to every question containing "yes"
+func answerByQuestionHandler(req LLMRequest) (string, error) {
+	if req.Schema.Name == "SummarizationAnswer" {
+		yes := strings.Contains(req.Prompt, "Please answer this question: yes")
+		return fmt.Sprintf(`{"answer": "answer", "yes_no": %t}`, yes), nil
+	}
+	return defaultFakeResponses[req.Schema.Name][0], nil
+}
+
+func TestAllQuestions(t *testing.T) {
+	tests := []struct {
+		name     string
+		scenario Scenario
+		want     []string
+	}{
+		{"single", Scenario{Question: "a"}, []string{"a"}},
+		{"list only", Scenario{Questions: []Question{{Text: "a"}, {Text: "b"}}}, []string{"a", "b"}},
+		{"both", Scenario{Question: "a", Questions: []Question{{Text: "b"}, {Text: "c"}}}, []string{"a", "b", "c"}},
+		{"none", Scenario{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, question := range tt.scenario.AllQuestions() {
+				got = append(got, question.Text)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("AllQuestions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnswerQuestionsKeepsOrder(t *testing.T) {
+	fake := NewFakeBackend()
+	fake.Handler = answerByQuestionHandler
+	var questions []Question
+	for _, text := range []string{"yes 1", "no 2", "yes 3", "no 4", "no 5"} {
+		questions = append(questions, Question{Text: text})
+	}
+
+	results, err := answerQuestions(context.Background(), questions, WorldState{}, nil, fake)
+	if err != nil {
+		t.Fatalf("answerQuestions returned error: %v", err)
+	}
+	for i, result := range results {
+		if result.Question != questions[i].Text || result.YesNo != strings.HasPrefix(questions[i].Text, "yes") {
+			t.Errorf("result %d = %+v, want an answer to %q", i, result, questions[i].Text)
+		}
+	}
+	if got := fake.CallCount("SummarizationAnswer"); got != len(questions) {
+		t.Errorf("SummarizationAnswer calls = %d, want %d", got, len(questions))
+	}
+}
+
+func TestRunSimulationBatchAggregatesEachQuestion(t *testing.T) {
+	fake := NewFakeBackend()
+	fake.Handler = answerByQuestionHandler
+	scenario := testScenario(1)
+	scenario.Question = "yes first"
+	scenario.Questions = []Question{{Text: "no second"}, {Text: "yes third"}}
+	baseDir := filepath.Join(t.TempDir(), "multi_sim_test")
+
+	if err := runSimulationBatch(context.Background(), scenario, 3, fake, baseDir); err != nil {
+		t.Fatalf("runSimulationBatch returned error: %v", err)
+	}
+
+	var aggregate AggregateResult
+	readJSONFile(t, filepath.Join(baseDir, "aggregate_results.json"), &aggregate)
+	want := []QuestionAggregate{
+		{Question: "yes first", Type: QuestionBinary, YesCount: 3, NoCount: 0, YesPercentage: 100, MeanProbability: floatPtr(1)},
+		{Question: "no second", Type: QuestionBinary, YesCount: 0, NoCount: 3, YesPercentage: 0, MeanProbability: floatPtr(0)},
+		{Question: "yes third", Type: QuestionBinary, YesCount: 3, NoCount: 0, YesPercentage: 100, MeanProbability: floatPtr(1)},
+	}
+	for i := range aggregate.Questions {
+		// Checked in TestYesIntervals
+		aggregate.Questions[i].YesInterval = nil
+		aggregate.Questions[i].YesCredibleInterval = nil
+		aggregate.Questions[i].SimulationsNeeded = 0
+	}
+	if !reflect.DeepEqual(aggregate.Questions, want) {
+		t.Errorf("aggregate questions = %+v, want %+v", aggregate.Questions, want)
+	}
+	// One world per simulation, however many questions are asked of it
+	if got := fake.CallCount("Actors"); got != 3 {
+		t.Errorf("Actors calls = %d, want 3", got)
+	}
+	for i, simulationResults := range aggregate.IndividualResults {
+		if len(simulationResults) != 3 {
+			t.Errorf("simulation %d has %d results, want 3", i+1, len(simulationResults))
+		}
+	}
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func TestQuestionJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want Question
+	}{
+		{"plain string is binary", `"Will it rain?"`, Question{Text: "Will it rain?"}},
+		{"numeric", `{"text": "Rate?", "type": "numeric", "unit": "%", "min": 0, "max": 5}`, Question{Text: "Rate?", Type: QuestionNumeric, Unit: "%", Min: floatPtr(0), Max: floatPtr(5)}},
+		{"categorical", `{"text": "Who wins?", "type": "categorical", "options": ["A", "B"]}`, Question{Text: "Who wins?", Type: QuestionCategorical, Options: []string{"A", "B"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Question
+			if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+				t.Fatalf("Unmarshal returned error: %v", err)
+			}
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(tt.want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("got %s, want %s", gotJSON, wantJSON)
+			}
+
+			// Round trip
+			var again Question
+			if err := json.Unmarshal(gotJSON, &again); err != nil {
+				t.Fatalf("Unmarshal of %s returned error: %v", gotJSON, err)
+			}
+			againJSON, _ := json.Marshal(again)
+			if string(againJSON) != string(gotJSON) {
+				t.Errorf("round trip changed %s into %s", gotJSON, againJSON)
+			}
+		})
+	}
+}
+
+func TestQuestionValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		question Question
+		wantErr  bool
+	}{
+		{"binary", Question{Text: "q"}, false},
+		{"empty", Question{Text: " "}, true},
+		{"numeric", Question{Text: "q", Type: QuestionNumeric, Min: floatPtr(1), Max: floatPtr(2)}, false},
+		{"numeric min above max", Question{Text: "q", Type: QuestionNumeric, Min: floatPtr(3), Max: floatPtr(2)}, true},
+		{"categorical", Question{Text: "q", Type: QuestionCategorical, Options: []string{"a", "b"}}, false},
+		{"categorical with one option", Question{Text: "q", Type: QuestionCategorical, Options: []string{"a"}}, true},
+		{"date", Question{Text: "q", Type: QuestionDate}, false},
+		{"unknown type", Question{Text: "q", Type: "vibes"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.question.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() = %v, want error: %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAnswerQuestionByType(t *testing.T) {
+	tests := []struct {
+		name        string
+		question    Question
+		reply       string
+		wantOutcome string
+		wantErr     bool
+	}{
+		{"binary", Question{Text: "q"}, `{"answer": "a", "yes_no": false}`, "false", false},
+		{"binary unresolved", Question{Text: "q"}, `{"answer": "a", "yes_no": true, "unresolved": true, "probability": 0.6}`, "unresolved", false},
+		{"numeric", Question{Text: "q", Type: QuestionNumeric}, `{"answer": "a", "value": 0.75}`, "0.75", false},
+		{"numeric clamped to max", Question{Text: "q", Type: QuestionNumeric, Max: floatPtr(5)}, `{"answer": "a", "value": 7}`, "5", false},
+		{"numeric clamped to min", Question{Text: "q", Type: QuestionNumeric, Min: floatPtr(0)}, `{"answer": "a", "value": -1}`, "0", false},
+		{"categorical", Question{Text: "q", Type: QuestionCategorical, Options: []string{"A", "B"}}, `{"answer": "a", "choice": "B"}`, "B", false},
+		{"date", Question{Text: "q", Type: QuestionDate}, `{"answer": "a", "date": "2026-03-31"}`, "2026-03-31", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := NewFakeBackend()
+			var gotSchema string
+			fake.Handler = func(req LLMRequest) (string, error) {
+				gotSchema = req.Schema.Name
+				return tt.reply, nil
+			}
+
+			result, err := answerQuestion(context.Background(), tt.question, WorldState{}, nil, fake)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("answerQuestion returned error: %v", err)
+			}
+			if result.Outcome() != tt.wantOutcome || result.Type != tt.question.kind() || result.Answer != "a" {
+				t.Errorf("result = %+v (outcome %q), want outcome %q", result, result.Outcome(), tt.wantOutcome)
+			}
+			wantSchema := map[string]string{
+				QuestionBinary:      "SummarizationAnswer",
+				QuestionNumeric:     "NumericAnswer",
+				QuestionCategorical: "CategoricalAnswer",
+				QuestionDate:        "DateAnswer",
+			}[tt.question.kind()]
+			if gotSchema != wantSchema {
+				t.Errorf("used schema %q, want %q", gotSchema, wantSchema)
+			}
+		})
+	}
+}
+
+func TestInvalidAnswersAreAskedAgain(t *testing.T) {
+	categorical := Question{Text: "q", Type: QuestionCategorical, Options: []string{"A", "B"}}
+	date := Question{Text: "q", Type: QuestionDate}
+	tests := []struct {
+		name        string
+		question    Question
+		schema      string
+		replies     []string
+		wantOutcome string
+	}{
+		{"categorical valid on second ask", categorical, "CategoricalAnswer", []string{`{"answer": "a", "choice": "C"}`, `{"answer": "a", "choice": "B"}`}, "B"},
+		{"categorical off list twice", categorical, "CategoricalAnswer", []string{`{"answer": "a", "choice": "C"}`, `{"answer": "a", "choice": "D"}`}, "unresolved"},
+		{"date valid on second ask", date, "DateAnswer", []string{`{"answer": "a", "date": "March 31"}`, `{"answer": "a", "date": "2026-03-31"}`}, "2026-03-31"},
+		{"malformed date twice", date, "DateAnswer", []string{`{"answer": "a", "date": "March 31"}`, `{"answer": "a", "date": "soon"}`}, "unresolved"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := NewFakeBackend()
+			fake.Responses[tt.schema] = tt.replies
+
+			result, err := answerQuestion(context.Background(), tt.question, WorldState{}, nil, fake)
+			if err != nil {
+				t.Fatalf("answerQuestion returned error: %v", err)
+			}
+			if len(fake.Calls()) != 2 {
+				t.Errorf("question asked %d times, want 2", len(fake.Calls()))
+			}
+			if result.Outcome() != tt.wantOutcome {
+				t.Errorf("outcome = %q, want %q", result.Outcome(), tt.wantOutcome)
+			}
+			if tt.wantOutcome == "unresolved" && !strings.Contains(result.Answer, "no valid answer after 2 attempts") {
+				t.Errorf("answer = %q, want it to say why it is unresolved", result.Answer)
+			}
+		})
+	}
+
+	// Simulations without a valid answer are counted, not dropped
+	answers := [][]SimulationResult{{{Type: QuestionCategorical, Choice: "A"}}, {{Type: QuestionCategorical, Unresolved: true}}}
+	aggregate := aggregateAnswers([]Question{categorical}, answers)[0]
+	if aggregate.UnresolvedCount != 1 || aggregate.Options[0].Count != 1 || aggregate.Options[0].Percentage != 50 {
+		t.Errorf("aggregate = %+v, want one unresolved answer and one A", aggregate)
+	}
+}
+
+func TestCategoricalSchemaRestrictsChoices(t *testing.T) {
+	fake := NewFakeBackend()
+	question := Question{Text: "q", Type: QuestionCategorical, Options: []string{"A", "B"}}
+	if _, err := answerQuestion(context.Background(), question, WorldState{}, nil, fake); err != nil {
+		t.Fatalf("answerQuestion returned error: %v", err)
+	}
+	schema, err := json.Marshal(fake.Calls()[0].Schema.Schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(schema), `"enum":["A","B"]`) {
+		t.Errorf("schema does not restrict choice to the options: %s", schema)
+	}
+}
+
+func TestAggregateAnswersByType(t *testing.T) {
+	numeric := Question{Text: "rate", Type: QuestionNumeric, Unit: "%", Min: floatPtr(0), Max: floatPtr(10)}
+	categorical := Question{Text: "winner", Type: QuestionCategorical, Options: []string{"A", "B", "C"}}
+	date := Question{Text: "when", Type: QuestionDate}
+	questions := []Question{numeric, categorical, date}
+
+	var results [][]SimulationResult
+	values := []float64{1, 2, 3, 4, 5}
+	choices := []string{"A", "A", "B", "A", "B"}
+	dates := []string{"2026-01-10", "2026-01-20", "2026-02-05", "2026-03-01", "2026-03-31"}
+	for i := range values {
+		results = append(results, []SimulationResult{
+			{Question: "rate", Type: QuestionNumeric, Value: &values[i]},
+			{Question: "winner", Type: QuestionCategorical, Choice: choices[i]},
+			{Question: "when", Type: QuestionDate, Date: dates[i]},
+		})
+	}
+
+	aggregates := aggregateAnswers(questions, results)
+
+	n := aggregates[0].Numeric
+	if n == nil {
+		t.Fatalf("no numeric summary")
+	}
+	if n.Mean != 3 || n.Median != 3 || n.Min != 1 || n.Max != 5 || n.P25 != 2 || n.P75 != 4 || n.Unit != "%" {
+		t.Errorf("unexpected numeric summary: %+v", n)
+	}
+	if len(n.Histogram) != numericHistogramBins || n.Histogram[0].Low != 0 || n.Histogram[len(n.Histogram)-1].High != 10 {
+		t.Errorf("histogram does not span the question's bounds: %+v", n.Histogram)
+	}
+	total := 0
+	for _, bin := range n.Histogram {
+		total += bin.Count
+	}
+	if total != len(values) {
+		t.Errorf("histogram counts %d values, want %d", total, len(values))
+	}
+
+	wantOptions := []OptionCount{{"A", 3, 60}, {"B", 2, 40}, {"C", 0, 0}}
+	if fmt.Sprint(aggregates[1].Options) != fmt.Sprint(wantOptions) {
+		t.Errorf("options = %+v, want %+v", aggregates[1].Options, wantOptions)
+	}
+
+	d := aggregates[2].Dates
+	if d == nil {
+		t.Fatalf("no date summary")
+	}
+	if d.Earliest != "2026-01-10" || d.Median != "2026-02-05" || d.Latest != "2026-03-31" {
+		t.Errorf("unexpected date summary: %+v", d)
+	}
+	wantMonths := []MonthCount{{"2026-01", 2}, {"2026-02", 1}, {"2026-03", 2}}
+	if fmt.Sprint(d.Histogram) != fmt.Sprint(wantMonths) {
+		t.Errorf("date histogram = %+v, want %+v", d.Histogram, wantMonths)
+	}
+}
+
+func TestBinaryAnswerProbability(t *testing.T) {
+	tests := []struct {
+		reply string
+		want  float64
+	}{
+		{`{"answer": "a", "yes_no": true, "unresolved": false, "probability": 0.9}`, 0.9},
+		{`{"answer": "a", "yes_no": true, "unresolved": false, "probability": 1.2}`, 1},
+		{`{"answer": "a", "yes_no": false, "unresolved": false, "probability": -0.1}`, 0},
+		// Servers that ignore the schema leave out the probability
+		{`{"answer": "a", "yes_no": true}`, 1},
+		{`{"answer": "a", "yes_no": false}`, 0},
+		{`{"answer": "a", "yes_no": false, "unresolved": true}`, 0.5},
+	}
+	for _, tt := range tests {
+		fake := NewFakeBackend()
+		fake.Responses["SummarizationAnswer"] = []string{tt.reply}
+		result, err := answerQuestion(context.Background(), Question{Text: "q"}, WorldState{}, nil, fake)
+		if err != nil {
+			t.Fatalf("answerQuestion returned error: %v", err)
+		}
+		if result.Probability == nil || *result.Probability != tt.want {
+			t.Errorf("%s: probability = %v, want %v", tt.reply, result.Probability, tt.want)
+		}
+	}
+}
+
+func TestAggregateBinaryAnswersWithUnresolved(t *testing.T) {
+	var results [][]SimulationResult
+	for _, answer := range []struct {
+		yes         bool
+		unresolved  bool
+		probability float64
+	}{
+		{true, false, 0.9},
+		{true, false, 0.8},
+		{false, false, 0.2},
+		{true, true, 0.6},
+		{false, true, 0.5},
+	} {
+		probability := answer.probability
+		results = append(results, []SimulationResult{{Question: "q", YesNo: answer.yes, Unresolved: answer.unresolved, Probability: &probability}})
+	}
+	// Saved before answers had probabilities
+	results = append(results, []SimulationResult{{Question: "q", YesNo: false}})
+
+	aggregate := aggregateAnswers([]Question{{Text: "q"}}, results)[0]
+	if aggregate.YesCount != 2 || aggregate.NoCount != 2 || aggregate.UnresolvedCount != 2 {
+		t.Errorf("yes, no, unresolved = %d, %d, %d, want 2, 2, 2", aggregate.YesCount, aggregate.NoCount, aggregate.UnresolvedCount)
+	}
+	if aggregate.YesPercentage != 50 {
+		t.Errorf("yes percentage = %v, want 50 (of resolved simulations)", aggregate.YesPercentage)
+	}
+	if math.Abs(aggregate.UnresolvedPercentage-100.0/3) > 1e-9 {
+		t.Errorf("unresolved percentage = %v, want 33.3", aggregate.UnresolvedPercentage)
+	}
+	if aggregate.MeanProbability == nil || math.Abs(*aggregate.MeanProbability-0.5) > 1e-9 {
+		t.Errorf("mean probability = %v, want 0.5", aggregate.MeanProbability)
+	}
+}
+
+func TestYesIntervals(t *testing.T) {
+	defer func(saved float64) { targetWidth = saved }(targetWidth)
+	targetWidth = 10
+
+	var results [][]SimulationResult
+	for i := 0; i < 10; i++ {
+		results = append(results, []SimulationResult{{Question: "q", YesNo: i < 7}})
+	}
+	// Unresolved simulations don't narrow the interval
+	for i := 0; i < 10; i++ {
+		results = append(results, []SimulationResult{{Question: "q", Unresolved: true}})
+	}
+
+	aggregate := aggregateAnswers([]Question{{Text: "q"}}, results)[0]
+	if aggregate.YesInterval == nil || aggregate.YesCredibleInterval == nil {
+		t.Fatalf("no intervals: %+v", aggregate)
+	}
+	if math.Abs(aggregate.YesInterval.Low-39.68) > 0.1 || math.Abs(aggregate.YesInterval.High-89.22) > 0.1 || aggregate.YesInterval.Level != 0.95 {
+		t.Errorf("yes interval = %+v, want [39.7, 89.2] at 0.95", *aggregate.YesInterval)
+	}
+	if math.Abs(aggregate.YesCredibleInterval.Low-39.42) > 0.1 || math.Abs(aggregate.YesCredibleInterval.High-90.73) > 0.1 {
+		t.Errorf("credible interval = %+v, want [39.4, 90.7]", *aggregate.YesCredibleInterval)
+	}
+	// About 320 resolved simulations are needed at 70%, and only half resolve
+	wantResolved := trialsForWidth(0.7, 10, 0.1, 0.95)
+	if want := 2 * (wantResolved - 10); aggregate.SimulationsNeeded != want {
+		t.Errorf("simulations needed = %d, want %d", aggregate.SimulationsNeeded, want)
+	}
+
+	targetWidth = 60
+	if aggregate := aggregateAnswers([]Question{{Text: "q"}}, results)[0]; aggregate.SimulationsNeeded != 0 {
+		t.Errorf("simulations needed = %d for an interval already narrower than the target", aggregate.SimulationsNeeded)
+	}
+
+	// Nothing resolved, nothing to estimate
+	if aggregate := aggregateAnswers([]Question{{Text: "q"}}, results[10:])[0]; aggregate.YesInterval != nil {
+		t.Errorf("interval without resolved answers: %+v", *aggregate.YesInterval)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// SimulationProgress is the part of a simulation that has already been run
+// and saved. The zero value means nothing has been run yet.
+type SimulationProgress struct {
+	Actors     *Actors
+	WorldState *WorldState     // World state after the last completed turn
+	Actions    [][]ActorAction // Actions of each completed turn
+	Memories   Memories        // Actors' memories of the completed turns
+}
+
+// readJSON unmarshals the JSON file at path into v
+func readJSON(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// loadSimulationProgress reconstructs how far the simulation saved in simDir
+// got. Turns count as complete only if both their actions and resulting world
+// state were saved; anything after the first incomplete turn is ignored and
+// will be rerun.
+func loadSimulationProgress(simDir string) SimulationProgress {
+	var progress SimulationProgress
+
+	var actors Actors
+	if err := readJSON(filepath.Join(simDir, "actors.json"), &actors); err != nil || len(actors.Actors) == 0 {
+		// Without actors nothing else can be reused
+		return progress
+	}
+	progress.Actors = &actors
+
+	var initialWorldState WorldState
+	if err := readJSON(filepath.Join(simDir, "initial_world_state.json"), &initialWorldState); err == nil {
+		progress.WorldState = &initialWorldState
+	}
+
+	for turn := 1; ; turn++ {
+		turnDir := filepath.Join(simDir, fmt.Sprintf("turn_%d", turn))
+		var actions []ActorAction
+		if err := readJSON(filepath.Join(turnDir, "actions.json"), &actions); err != nil {
+			break
+		}
+		var worldState WorldState
+		if err := readJSON(filepath.Join(turnDir, "world_state.json"), &worldState); err != nil {
+			break
+		}
+		progress.Actions = append(progress.Actions, actions)
+		progress.WorldState = &worldState
+	}
+	progress.Memories = loadMemories(simDir, actors, len(progress.Actions))
+	return progress
+}
+
+// loadSimulationResults returns the saved results of a finished simulation,
+// or false if it hasn't finished or was answering different questions
+func loadSimulationResults(simDir string, questions []Question) ([]SimulationResult, bool) {
+	var results []SimulationResult
+	if err := readJSON(filepath.Join(simDir, "result.json"), &results); err != nil {
+		return nil, false
+	}
+	if len(results) != len(questions) {
+		return nil, false
+	}
+	for i, result := range results {
+		if result.Question != questions[i].Text {
+			return nil, false
+		}
+	}
+	return results, true
+}
+
+var simulationDirPattern = regexp.MustCompile(`^simulation_(\d+)$`)
+
+// countSimulationDirs returns the highest N among baseDir/simulation_N
+func countSimulationDirs(baseDir string) (int, error) {
+	entries, err := ioutil.ReadDir(baseDir)
+	if err != nil {
+		return 0, err
+	}
+	highest := 0
+	for _, entry := range entries {
+		match := simulationDirPattern.FindStringSubmatch(entry.Name())
+		if !entry.IsDir() || match == nil {
+			continue
+		}
+		if n, err := strconv.Atoi(match[1]); err == nil && n > highest {
+			highest = n
+		}
+	}
+	return highest, nil
+}
+
+// resumeMultipleSimulations finishes an interrupted multi_sim directory. If
+// numSimulations is 0, it finishes as many simulations as scenario.json asks
+// for; a larger number extends the batch.
+func resumeMultipleSimulations(ctx context.Context, baseDir string, scenario Scenario, numSimulations int, backend LLMBackend) error {
+	if _, err := os.Stat(baseDir); err != nil {
+		return fmt.Errorf("cannot resume %s: %v", baseDir, err)
+	}
+
+	if numSimulations == 0 {
+		numSimulations = scenario.NumSimulations
+	}
+	if numSimulations == 0 {
+		// scenario.json files written before num_simulations was recorded
+		found, err := countSimulationDirs(baseDir)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", baseDir, err)
+		}
+		numSimulations = found
+	}
+	if numSimulations == 0 {
+		return fmt.Errorf("cannot tell how many simulations %s should have", baseDir)
+	}
+
+	fmt.Printf("\nResuming %s\n", baseDir)
+	var err error
+	if maxSimulations > 0 {
+		err = runAdaptiveSimulations(ctx, scenario, numSimulations, backend, baseDir)
+	} else {
+		err = runSimulationBatch(ctx, scenario, numSimulations, backend, baseDir)
+	}
+	if err != nil {
+		return err
+	}
+	recordForecasts(filepath.Join(baseDir, "aggregate_results.json"))
+	return nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path string, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadSimulationProgress(t *testing.T) {
+	actors := `{"Actors": [{"name": "A", "goals": "g", "powers": "p"}], "observations": ""}`
+	worldState := func(description string) string {
+		return `{"events": [], "description": "` + description + `"}`
+	}
+
+	tests := []struct {
+		name            string
+		files           map[string]string
+		wantActors      bool
+		wantTurns       int
+		wantDescription string
+	}{
+		{"empty", nil, false, 0, ""},
+		{"actors only", map[string]string{"actors.json": actors}, true, 0, ""},
+		{"initial state", map[string]string{"actors.json": actors, "initial_world_state.json": worldState("initial")}, true, 0, "initial"},
+		{"two turns", map[string]string{
+			"actors.json":              actors,
+			"initial_world_state.json": worldState("initial"),
+			"turn_1/actions.json":      `[]`,
+			"turn_1/world_state.json":  worldState("turn 1"),
+			"turn_2/actions.json":      `[]`,
+			"turn_2/world_state.json":  worldState("turn 2"),
+		}, true, 2, "turn 2"},
+		{"half-written turn", map[string]string{
+			"actors.json":              actors,
+			"initial_world_state.json": worldState("initial"),
+			"turn_1/actions.json":      `[]`,
+			"turn_1/world_state.json":  worldState("turn 1"),
+			"turn_2/actions.json":      `[]`,
+			"turn_2/world_state.json":  `{"events": [`,
+		}, true, 1, "turn 1"},
+		{"turn with actions only", map[string]string{
+			"actors.json":              actors,
+			"initial_world_state.json": worldState("initial"),
+			"turn_1/actions.json":      `[]`,
+		}, true, 0, "initial"},
+		{"saved before initial state was recorded", map[string]string{
+			"actors.json":             actors,
+			"turn_1/actions.json":     `[]`,
+			"turn_1/world_state.json": worldState("turn 1"),
+		}, true, 1, "turn 1"},
+		{"gap in turns", map[string]string{
+			"actors.json":              actors,
+			"initial_world_state.json": worldState("initial"),
+			"turn_2/actions.json":      `[]`,
+			"turn_2/world_state.json":  worldState("turn 2"),
+		}, true, 0, "initial"},
+		{"corrupt actors", map[string]string{"actors.json": `{`, "initial_world_state.json": worldState("initial")}, false, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			simDir := t.TempDir()
+			for name, contents := range tt.files {
+				os.MkdirAll(filepath.Dir(filepath.Join(simDir, name)), 0755)
+				writeFile(t, filepath.Join(simDir, name), contents)
+			}
+
+			progress := loadSimulationProgress(simDir)
+			if (progress.Actors != nil) != tt.wantActors {
+				t.Errorf("actors loaded: %t, want %t", progress.Actors != nil, tt.wantActors)
+			}
+			if len(progress.Actions) != tt.wantTurns {
+				t.Errorf("%d turns complete, want %d", len(progress.Actions), tt.wantTurns)
+			}
+			description := ""
+			if progress.WorldState != nil {
+				description = progress.WorldState.Description
+			}
+			if description != tt.wantDescription {
+				t.Errorf("world state %q, want %q", description, tt.wantDescription)
+			}
+		})
+	}
+}
+
+func TestResumeFinishesOnlyMissingWork(t *testing.T) {
+	baseDir := filepath.Join(t.TempDir(), "multi_sim_test")
+	scenario := testScenario(3)
+	if err := runSimulationBatch(context.Background(), scenario, 3, NewFakeBackend(), baseDir); err != nil {
+		t.Fatalf("runSimulationBatch returned error: %v", err)
+	}
+
+	// Simulation 1 finished. Simulation 2 was interrupted during turn 3, and
+	// simulation 3 while writing the world state of turn 2.
+	sim2 := filepath.Join(baseDir, "simulation_2")
+	os.Remove(filepath.Join(sim2, "result.json"))
+	os.RemoveAll(filepath.Join(sim2, "turn_3"))
+	writeFile(t, filepath.Join(sim2, "turn_2", "world_state.json"), `{"events": [], "description": "saved turn 2 state"}`)
+	sim3 := filepath.Join(baseDir, "simulation_3")
+	os.Remove(filepath.Join(sim3, "result.json"))
+	os.RemoveAll(filepath.Join(sim3, "turn_3"))
+	writeFile(t, filepath.Join(sim3, "turn_2", "world_state.json"), `{"events": [`)
+	os.Remove(filepath.Join(baseDir, "aggregate_results.json"))
+
+	loaded, err := LoadScenario(filepath.Join(baseDir, "scenario.json"))
+	if err != nil {
+		t.Fatalf("LoadScenario returned error: %v", err)
+	}
+	fake := NewFakeBackend()
+	if err := resumeMultipleSimulations(context.Background(), baseDir, loaded, 0, fake); err != nil {
+		t.Fatalf("resumeMultipleSimulations returned error: %v", err)
+	}
+
+	// Simulation 2 reruns turn 3, simulation 3 reruns turns 2 and 3, each
+	// with 2 actors. Nobody regenerates actors or the initial world state.
+	wantCalls := map[string]int{
+		"Actors":              0,
+		"ActorView":           6,
+		"ActorAction":         6,
+		"WorldState":          3,
+		"SummarizationAnswer": 2,
+	}
+	for schema, want := range wantCalls {
+		if got := fake.CallCount(schema); got != want {
+			t.Errorf("%s calls = %d, want %d", schema, got, want)
+		}
+	}
+
+	resumedFromSavedState := false
+	for _, call := range fake.Calls() {
+		if call.Schema.Name == "ActorView" && strings.Contains(call.Prompt, "saved turn 2 state") {
+			resumedFromSavedState = true
+		}
+	}
+	if !resumedFromSavedState {
+		t.Errorf("simulation 2 did not continue from its saved turn 2 world state")
+	}
+
+	var aggregate AggregateResult
+	readJSONFile(t, filepath.Join(baseDir, "aggregate_results.json"), &aggregate)
+	if aggregate.Total != 3 || aggregate.Questions[0].YesCount != 3 {
+		t.Errorf("unexpected aggregate after resuming: %+v", aggregate)
+	}
+	for _, sim := range []string{sim2, sim3} {
+		if _, err := os.Stat(filepath.Join(sim, "turn_3", "world_state.json")); err != nil {
+			t.Errorf("%s: turn 3 not rerun", sim)
+		}
+	}
+}
+
+func TestResumeWithoutRecordedSimulationCount(t *testing.T) {
+	baseDir := t.TempDir()
+	for _, name := range []string{"simulation_1", "simulation_2", "simulation_4", "other"} {
+		os.MkdirAll(filepath.Join(baseDir, name), 0755)
+	}
+	if n, err := countSimulationDirs(baseDir); err != nil || n != 4 {
+		t.Fatalf("countSimulationDirs = %d, %v; want 4", n, err)
+	}
+
+	fake := NewFakeBackend()
+	if err := resumeMultipleSimulations(context.Background(), baseDir, testScenario(1), 0, fake); err != nil {
+		t.Fatalf("resumeMultipleSimulations returned error: %v", err)
+	}
+	if got := fake.CallCount("Actors"); got != 4 {
+		t.Errorf("Actors calls = %d, want 4", got)
+	}
+}
+
+func TestFailedBatchCanBeResumed(t *testing.T) {
+	baseDir := filepath.Join(t.TempDir(), "multi_sim_test")
+	fake := NewFakeBackend()
+	// Only the first simulation to generate actors fails
+	failFirstActors(fake, 1)
+
+	if err := runSimulationBatch(context.Background(), testScenario(1), 3, fake, baseDir); err != nil {
+		t.Fatalf("runSimulationBatch returned error: %v", err)
+	}
+	complete, _ := filepath.Glob(filepath.Join(baseDir, "simulation_*", "result.json"))
+	if len(complete) != 2 {
+		t.Fatalf("%d simulations saved their results, want the 2 that did not fail", len(complete))
+	}
+
+	resumed := NewFakeBackend()
+	if err := resumeMultipleSimulations(context.Background(), baseDir, testScenario(1), 3, resumed); err != nil {
+		t.Fatalf("resumeMultipleSimulations returned error: %v", err)
+	}
+	if got := resumed.CallCount("Actors"); got != 1 {
+		t.Errorf("Actors calls on resume = %d, want 1", got)
+	}
+	var aggregate AggregateResult
+	readJSONFile(t, filepath.Join(baseDir, "aggregate_results.json"), &aggregate)
+	if aggregate.Successful != 3 || aggregate.Failed != 0 {
+		t.Errorf("aggregate after resume: %d successful, %d failed; want 3, 0", aggregate.Successful, aggregate.Failed)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is everything needed to run a simulation without prompting the
+// user. It is read from --scenario files and written to scenario.json in
+// each multi_sim directory, so a saved run can be fed back in as input.
+type Scenario struct {
+	Scenario       string        `json:"scenario"`
+	Question       string        `json:"question"`
+	Questions      []Question    `json:"questions,omitempty"` // Further questions, answered against the same final state
+	Turns          int           `json:"turns"`
+	NumSimulations int           `json:"num_simulations,omitempty"`
+	Actors         *Actors       `json:"actors,omitempty"`        // Skip actor generation and use these instead
+	ExternalInfo   string        `json:"external_info,omitempty"` // Used to adjust actors and the initial world state
+	Models         *ModelConfig  `json:"models,omitempty"`
+	StartDate      string        `json:"start_date,omitempty"`    // YYYY-MM-DD; with TurnDuration, gives every turn a simulated date
+	TurnDuration   string        `json:"turn_duration,omitempty"` // Simulated time per turn, e.g. "1 week" or "3 months"
+	Negotiations   []Negotiation `json:"negotiations,omitempty"`  // Bargaining between actors inside turns
+	Variables      []Variable    `json:"variables,omitempty"`     // Quantities tracked in the world state, e.g. a policy rate
+	Shocks         []Shock       `json:"shocks,omitempty"`        // Exogenous events that can fire at the start of turns
+	Seed           int64         `json:"seed,omitempty"`          // For drawing shocks; picked at random for each new run if 0
+}
+
+// The scenario used when no mode is selected
+var defaultScenario = Scenario{
+	Scenario: "The Bank of Japan is considering what to do about rates. I am curious about how to balance the central bank of Japan changing rates with the needs of the Japanese people, the PM, but also possible external pressure to not unwind the Japanese carry trade.",
+	Question: "Did the Bank of Japan raise rates, potentially unwinding the Japanese carry trade?",
+	Turns:    2,
+}
+
+// LoadScenario reads a scenario from a JSON file, or a YAML one if its
+// extension is .yaml or .yml
+func LoadScenario(path string) (Scenario, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Scenario{}, fmt.Errorf("failed to read scenario file: %v", err)
+	}
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		data, err = yamlToJSON(data)
+		if err != nil {
+			return Scenario{}, fmt.Errorf("failed to parse scenario file %s: %v", path, err)
+		}
+	}
+
+	var scenario Scenario
+	if err := json.Unmarshal(data, &scenario); err != nil {
+		return Scenario{}, fmt.Errorf("failed to parse scenario file %s: %v", path, err)
+	}
+
+	if strings.TrimSpace(scenario.Scenario) == "" {
+		return Scenario{}, fmt.Errorf("scenario file %s has no \"scenario\" description", path)
+	}
+	if len(scenario.AllQuestions()) == 0 {
+		return Scenario{}, fmt.Errorf("scenario file %s has no \"question\" or \"questions\"", path)
+	}
+	for _, question := range scenario.AllQuestions() {
+		if err := question.validate(); err != nil {
+			return Scenario{}, fmt.Errorf("scenario file %s: %v", path, err)
+		}
+	}
+	if scenario.Turns < 0 {
+		return Scenario{}, fmt.Errorf("scenario file %s has a negative number of turns", path)
+	}
+	if scenario.NumSimulations < 0 {
+		return Scenario{}, fmt.Errorf("scenario file %s has a negative number of simulations", path)
+	}
+	if scenario.Actors != nil && len(scenario.Actors.Actors) == 0 {
+		return Scenario{}, fmt.Errorf("scenario file %s has an empty list of actors", path)
+	}
+	if scenario.Actors != nil {
+		if err := checkActorNames(*scenario.Actors); err != nil {
+			return Scenario{}, fmt.Errorf("scenario file %s: %v", path, err)
+		}
+		for _, actor := range scenario.Actors.Actors {
+			if actor.Cadence < 0 {
+				return Scenario{}, fmt.Errorf("scenario file %s: actor %q has a negative cadence", path, actor.Name)
+			}
+		}
+	}
+	for _, negotiation := range scenario.Negotiations {
+		if err := negotiation.validate(); err != nil {
+			return Scenario{}, fmt.Errorf("scenario file %s: %v", path, err)
+		}
+		// Generated actors can only be checked once the simulation runs
+		if scenario.Actors != nil {
+			if _, err := findParties(negotiation, *scenario.Actors); err != nil {
+				return Scenario{}, fmt.Errorf("scenario file %s: negotiation %q: %v", path, negotiation.Topic, err)
+			}
+		}
+	}
+	for i, variable := range scenario.Variables {
+		if err := variable.validate(); err != nil {
+			return Scenario{}, fmt.Errorf("scenario file %s: %v", path, err)
+		}
+		if _, ok := findVariable(scenario.Variables[:i], variable.Name); ok {
+			return Scenario{}, fmt.Errorf("scenario file %s: variable %q is declared twice", path, variable.Name)
+		}
+	}
+	for i, shock := range scenario.Shocks {
+		if err := shock.validate(); err != nil {
+			return Scenario{}, fmt.Errorf("scenario file %s: %v", path, err)
+		}
+		for _, other := range scenario.Shocks[:i] {
+			if strings.EqualFold(other.Name, shock.Name) {
+				return Scenario{}, fmt.Errorf("scenario file %s: shock %q is declared twice", path, shock.Name)
+			}
+		}
+	}
+	for i, question := range scenario.Questions {
+		if question.Variable == "" {
+			continue
+		}
+		variable, ok := findVariable(scenario.Variables, question.Variable)
+		if !ok {
+			return Scenario{}, fmt.Errorf("scenario file %s: question %q reads variable %q, which is not declared", path, question.Text, question.Variable)
+		}
+		scenario.Questions[i] = question.readingVariable(variable)
+	}
+	if _, err := scenario.Calendar(); err != nil {
+		return Scenario{}, fmt.Errorf("scenario file %s: %v", path, err)
+	}
+	for _, question := range scenario.AllQuestions() {
+		// Dates in the same format compare as strings
+		if question.Deadline != "" && question.Deadline < scenario.StartDate {
+			return Scenario{}, fmt.Errorf("scenario file %s: question %q has a deadline before the start date", path, question.Text)
+		}
+	}
+	return scenario, nil
+}
+
+// yamlToJSON converts a YAML document to JSON, so that YAML scenario files
+// have the same keys and checks as JSON ones
+func yamlToJSON(data []byte) ([]byte, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	keepDatesAsText(&document)
+	var value interface{}
+	if err := document.Decode(&value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// keepDatesAsText makes unquoted dates, such as start_date: 2026-01-05,
+// strings, as YAML would otherwise decode them into times
+func keepDatesAsText(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!timestamp" {
+		node.Tag = "!!str"
+	}
+	for _, child := range node.Content {
+		keepDatesAsText(child)
+	}
+}
+
+// readScenario asks the user for the scenario description, number of turns
+// and question on stdin
+func readScenario() Scenario {
+	var scenario Scenario
+
+	// Get scenario from user
+	scenario.Scenario = readInput("Enter the scenario description")
+
+	// Get number of turns
+	fmt.Print("\nEnter number of turns to simulate: ")
+	fmt.Scanf("%d\n", &scenario.Turns)
+
+	// Get summarization question
+	scenario.Question = readInput("Enter the question to answer at the end")
+
+	return scenario
+}
+
+// setupActors returns the scenario's pre-defined actors, or generates them,
+// adjusting them for the scenario's external information if there is any
+func setupActors(ctx context.Context, scenario Scenario, backend LLMBackend) (Actors, error) {
+	var actors Actors
+	if scenario.Actors != nil {
+		actors = *scenario.Actors
+	} else {
+		generated, err := GetActors(ctx, scenario.Scenario, backend)
+		if err != nil {
+			return Actors{}, err
+		}
+		actors = generated
+	}
+
+	if scenario.ExternalInfo != "" {
+		adjusted, err := AdjustActors(ctx, actors, scenario.ExternalInfo, backend)
+		if err != nil {
+			return Actors{}, fmt.Errorf("failed to adjust actors: %v", err)
+		}
+		actors = adjusted
+	}
+	// Generated or adjusted actors can collide too
+	if err := checkActorNames(actors); err != nil {
+		return Actors{}, err
+	}
+	return actors, nil
+}
+
+// situationWithExternalInfo is the situation description given to
+// SummarizeWorldState, including any external information, when the
+// simulation starts and the variables it tracks
+func situationWithExternalInfo(scenario Scenario) string {
+	situation := scenario.Scenario
+	if scenario.ExternalInfo != "" {
+		situation += "\n\nAdditional external information: " + scenario.ExternalInfo
+	}
+	if scenario.StartDate != "" {
+		situation += fmt.Sprintf("\n\nThe simulation starts on %s, and each turn covers %s. Start each event with the date on which it happened, as [YYYY-MM-DD].", scenario.StartDate, scenario.TurnDuration)
+	}
+	if len(scenario.Variables) > 0 {
+		var initial []string
+		for _, variable := range scenario.Variables {
+			initial = append(initial, fmt.Sprintf("%s = %g", variable.Name, variable.Initial))
+		}
+		situation += fmt.Sprintf("\n\nThe world state tracks these variables:\n%s\nThey start at %s.", describeVariables(scenario.Variables), strings.Join(initial, ", "))
+	}
+	return situation
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeScenarioFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "scenario.json")
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadScenario(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		wantErr  string
+	}{
+		{"minimal", `{"scenario": "s", "question": "q?", "turns": 2}`, ""},
+		{"full", `{"scenario": "s", "question": "q?", "turns": 2, "num_simulations": 5, "actors": {"Actors": [{"name": "A", "goals": "g", "powers": "p"}], "observations": ""}, "external_info": "news", "models": {"answer": "m"}}`, ""},
+		{"questions only", `{"scenario": "s", "questions": ["q1?", {"text": "Rate?", "type": "numeric", "unit": "%"}], "turns": 2}`, ""},
+		{"invalid question type", `{"scenario": "s", "question": "q?", "questions": [{"text": "Who?", "type": "categorical", "options": ["A"]}], "turns": 2}`, "at least two options"},
+		{"missing scenario", `{"question": "q?", "turns": 2}`, `no "scenario"`},
+		{"missing question", `{"scenario": "s", "turns": 2}`, `no "question"`},
+		{"negative turns", `{"scenario": "s", "question": "q?", "turns": -1}`, "negative number of turns"},
+		{"empty actors", `{"scenario": "s", "question": "q?", "turns": 1, "actors": {"Actors": []}}`, "empty list of actors"},
+		{"calendar", `{"scenario": "s", "questions": [{"text": "q?", "deadline": "2026-03-31"}], "turns": 2, "start_date": "2026-01-01", "turn_duration": "1 month"}`, ""},
+		{"start date without duration", `{"scenario": "s", "question": "q?", "turns": 2, "start_date": "2026-01-01"}`, "must be given together"},
+		{"invalid turn duration", `{"scenario": "s", "question": "q?", "turns": 2, "start_date": "2026-01-01", "turn_duration": "1 fortnight"}`, "unknown unit"},
+		{"invalid start date", `{"scenario": "s", "question": "q?", "turns": 2, "start_date": "01/01/2026", "turn_duration": "1 week"}`, "YYYY-MM-DD"},
+		{"invalid deadline", `{"scenario": "s", "questions": [{"text": "q?", "deadline": "soon"}], "turns": 2}`, "deadline"},
+		{"deadline before start", `{"scenario": "s", "questions": [{"text": "q?", "deadline": "2025-12-31"}], "turns": 2, "start_date": "2026-01-01", "turn_duration": "1 week"}`, "before the start date"},
+		{"negative cadence", `{"scenario": "s", "question": "q?", "turns": 1, "actors": {"Actors": [{"name": "A", "goals": "g", "powers": "p", "cadence": -1}]}}`, "negative cadence"},
+		{"negotiation", `{"scenario": "s", "question": "q?", "turns": 2, "negotiations": [{"topic": "tariffs", "parties": ["A", "B"], "max_rounds": 3, "turns": [2]}]}`, ""},
+		{"actors with the same name", `{"scenario": "s", "question": "q?", "turns": 2, "actors": {"Actors": [{"name": "Fed", "goals": "g", "powers": "p"}, {"name": "FED", "goals": "g", "powers": "p"}], "observations": ""}}`, `actors "Fed" and "FED" have the same name`},
+		{"negotiation with a party twice", `{"scenario": "s", "question": "q?", "turns": 2, "negotiations": [{"topic": "rates", "parties": ["Fed", " fed"]}]}`, `has "fed" as a party twice`},
+		{"negotiation with one party", `{"scenario": "s", "question": "q?", "turns": 2, "negotiations": [{"topic": "tariffs", "parties": ["A"]}]}`, "at least two parties"},
+		{"negotiation on turn 0", `{"scenario": "s", "question": "q?", "turns": 2, "negotiations": [{"topic": "tariffs", "parties": ["A", "B"], "turns": [0]}]}`, "turns start at 1"},
+		{"negotiation between actors", `{"scenario": "s", "question": "q?", "turns": 2, "actors": {"Actors": [{"name": "A", "goals": "g", "powers": "p"}, {"name": "B", "goals": "g", "powers": "p"}], "observations": ""}, "negotiations": [{"topic": "tariffs", "parties": ["a", " B"]}]}`, ""},
+		{"negotiation with a party that is not an actor", `{"scenario": "s", "question": "q?", "turns": 2, "actors": {"Actors": [{"name": "A", "goals": "g", "powers": "p"}, {"name": "B", "goals": "g", "powers": "p"}], "observations": ""}, "negotiations": [{"topic": "tariffs", "parties": ["A", "C"]}]}`, `party "C" is not one of the actors`},
+		{"variables", `{"scenario": "s", "questions": [{"text": "Rate?", "variable": "policy rate"}], "turns": 2, "variables": [{"name": "Policy rate", "unit": "%", "initial": 0.5, "min": 0, "max": 10}]}`, ""},
+		{"variable outside its bounds", `{"scenario": "s", "question": "q?", "turns": 2, "variables": [{"name": "Policy rate", "initial": 12, "max": 10}]}`, "outside its bounds"},
+		{"variable declared twice", `{"scenario": "s", "question": "q?", "turns": 2, "variables": [{"name": "Rate", "initial": 1}, {"name": "rate", "initial": 2}]}`, "declared twice"},
+		{"question reads undeclared variable", `{"scenario": "s", "questions": [{"text": "Rate?", "variable": "Rate"}], "turns": 2}`, "not declared"},
+		{"binary question reads variable", `{"scenario": "s", "questions": [{"text": "Rate?", "type": "binary", "variable": "Rate"}], "turns": 2, "variables": [{"name": "Rate", "initial": 1}]}`, "not numeric"},
+		{"shocks", `{"scenario": "s", "question": "q?", "turns": 2, "shocks": [{"name": "CPI", "event": "CPI surprises", "probability": 0.2}, {"name": "Quake", "event": "An earthquake", "turns": [2]}]}`, ""},
+		{"shock probability above 1", `{"scenario": "s", "question": "q?", "turns": 2, "shocks": [{"name": "CPI", "event": "CPI surprises", "probability": 1.5}]}`, "outside [0, 1]"},
+		{"shock without event", `{"scenario": "s", "question": "q?", "turns": 2, "shocks": [{"name": "CPI"}]}`, "has no event"},
+		{"shock declared twice", `{"scenario": "s", "question": "q?", "turns": 2, "shocks": [{"name": "CPI", "event": "e"}, {"name": "cpi", "event": "e"}]}`, "declared twice"},
+		{"invalid json", `{"scenario": `, "failed to parse"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadScenario(writeScenarioFile(t, tt.contents))
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadYAMLScenario(t *testing.T) {
+	yamlScenario := `scenario: The Bank of Japan is considering a rate hike
+question: Did the Bank of Japan raise rates?
+turns: 2
+actors:
+  Actors:
+    - name: Bank of Japan
+      goals: Keep inflation near 2%
+      powers: Sets rates
+      cadence: 2
+    - name: Prime Minister
+      goals: Win the next election
+      powers: Appoints the governor
+  observations: ""
+models:
+  filter: gpt-5-mini
+start_date: 2026-01-05
+turn_duration: 1 month
+shocks:
+  - name: CPI
+    event: "CPI comes in far above expectations: 4%"
+    probability: 0.2
+seed: 42
+`
+	jsonScenario := `{"scenario": "The Bank of Japan is considering a rate hike", "question": "Did the Bank of Japan raise rates?", "turns": 2,
+"actors": {"Actors": [{"name": "Bank of Japan", "goals": "Keep inflation near 2%", "powers": "Sets rates", "cadence": 2}, {"name": "Prime Minister", "goals": "Win the next election", "powers": "Appoints the governor"}], "observations": ""},
+"models": {"filter": "gpt-5-mini"}, "start_date": "2026-01-05", "turn_duration": "1 month",
+"shocks": [{"name": "CPI", "event": "CPI comes in far above expectations: 4%", "probability": 0.2}], "seed": 42}`
+
+	for _, name := range []string{"scenario.yaml", "scenario.yml"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, []byte(yamlScenario), 0644); err != nil {
+			t.Fatal(err)
+		}
+		fromYAML, err := LoadScenario(path)
+		if err != nil {
+			t.Fatalf("LoadScenario(%s) returned error: %v", name, err)
+		}
+		fromJSON, err := LoadScenario(writeScenarioFile(t, jsonScenario))
+		if err != nil {
+			t.Fatalf("LoadScenario returned error: %v", err)
+		}
+		if !reflect.DeepEqual(fromYAML, fromJSON) {
+			t.Errorf("%s loaded as %+v, want %+v", name, fromYAML, fromJSON)
+		}
+	}
+
+	// YAML files are checked like JSON ones
+	path := filepath.Join(t.TempDir(), "scenario.yaml")
+	if err := os.WriteFile(path, []byte("scenario: s\nturns: 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadScenario(path); err == nil || !strings.Contains(err.Error(), "no \"question\"") {
+		t.Errorf("error = %v, want a missing question", err)
+	}
+	if err := os.WriteFile(path, []byte("scenario: [s\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadScenario(path); err == nil || !strings.Contains(err.Error(), "failed to parse") {
+		t.Errorf("error = %v, want a parse error", err)
+	}
+}
+
+func TestSavedScenarioCanBeLoaded(t *testing.T) {
+	baseDir := filepath.Join(t.TempDir(), "multi_sim_test")
+	scenario := testScenario(1)
+	scenario.ExternalInfo = "news"
+	if err := runSimulationBatch(context.Background(), scenario, 2, NewFakeBackend(), baseDir); err != nil {
+		t.Fatalf("runSimulationBatch returned error: %v", err)
+	}
+
+	loaded, err := LoadScenario(filepath.Join(baseDir, "scenario.json"))
+	if err != nil {
+		t.Fatalf("LoadScenario returned error: %v", err)
+	}
+	if loaded.Scenario != scenario.Scenario || loaded.Question != scenario.Question || loaded.Turns != 1 || loaded.NumSimulations != 2 || loaded.ExternalInfo != "news" {
+		t.Errorf("loaded scenario does not match the one run: %+v", loaded)
+	}
+	if loaded.Models == nil || *loaded.Models != models {
+		t.Errorf("loaded scenario does not record the models: %+v", loaded.Models)
+	}
+}
+
+func TestSetupActors(t *testing.T) {
+	predefined := makeActors(3)
+	tests := []struct {
+		name            string
+		actors          *Actors
+		externalInfo    string
+		wantGenerated   int
+		wantAdjusted    int
+		wantActorsCount int
+	}{
+		{"generated", nil, "", 1, 0, 2},
+		{"predefined", &predefined, "", 0, 0, 3},
+		{"generated and adjusted", nil, "news", 1, 1, 2},
+		{"predefined and adjusted", &predefined, "news", 0, 1, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := NewFakeBackend()
+			scenario := testScenario(1)
+			scenario.Actors = tt.actors
+			scenario.ExternalInfo = tt.externalInfo
+
+			actors, err := setupActors(context.Background(), scenario, fake)
+			if err != nil {
+				t.Fatalf("setupActors returned error: %v", err)
+			}
+			// GetActors and AdjustActors share the "Actors" schema; AdjustActors
+			// is the one whose prompt contains the external information
+			generated, adjusted := 0, 0
+			for _, call := range fake.Calls() {
+				if call.Schema.Name != "Actors" {
+					continue
+				}
+				if strings.Contains(call.Prompt, "new external information") {
+					adjusted++
+				} else {
+					generated++
+				}
+			}
+			if generated != tt.wantGenerated || adjusted != tt.wantAdjusted {
+				t.Errorf("generated %d times and adjusted %d times, want %d and %d", generated, adjusted, tt.wantGenerated, tt.wantAdjusted)
+			}
+			if len(actors.Actors) != tt.wantActorsCount {
+				t.Errorf("got %d actors, want %d", len(actors.Actors), tt.wantActorsCount)
+			}
+		})
+	}
+}
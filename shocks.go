@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+	jsonschema "github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// Shock is an exogenous event that nature can inject into the world at the
+// start of a turn, before the actors observe it, such as a bad CPI print or
+// an earthquake
+type Shock struct {
+	Name        string   `json:"name"`
+	Event       string   `json:"event"`                 // Added to the world state's events when the shock fires
+	Probability *float64 `json:"probability,omitempty"` // Of firing on each turn; estimated by the model every turn if omitted
+	Turns       []int    `json:"turns,omitempty"`       // Turns it can fire on; every turn if empty
+}
+
+// ShockDraw records whether a shock fired on a turn, and with what probability
+type ShockDraw struct {
+	Shock       string  `json:"shock"`
+	Turn        int     `json:"turn"`
+	Probability float64 `json:"probability"`
+	Estimated   bool    `json:"estimated,omitempty"` // By the model, which gives its reasoning
+	Reasoning   string  `json:"reasoning,omitempty"`
+	Fired       bool    `json:"fired"`
+}
+
+// ShockEstimate is the model's probability that a shock happens in a turn
+type ShockEstimate struct {
+	Shock       string  `json:"shock"`
+	Probability float64 `json:"probability"`
+	Reasoning   string  `json:"reasoning"`
+}
+
+// ShockEstimates is the model's reply when asked for shock probabilities
+type ShockEstimates struct {
+	Estimates []ShockEstimate `json:"estimates"`
+}
+
+// validate checks that the shock is fully specified
+func (s Shock) validate() error {
+	if strings.TrimSpace(s.Name) == "" {
+		return fmt.Errorf("shock without a name")
+	}
+	if strings.TrimSpace(s.Event) == "" {
+		return fmt.Errorf("shock %q has no event", s.Name)
+	}
+	if s.Probability != nil && (*s.Probability < 0 || *s.Probability > 1) {
+		return fmt.Errorf("shock %q has a probability outside [0, 1]", s.Name)
+	}
+	for _, turn := range s.Turns {
+		if turn < 1 {
+			return fmt.Errorf("shock %q can fire on turn %d; turns start at 1", s.Name, turn)
+		}
+	}
+	return nil
+}
+
+// canFireOnTurn reports whether the shock can fire on turn n
+func (s Shock) canFireOnTurn(turn int) bool {
+	if len(s.Turns) == 0 {
+		return true
+	}
+	for _, t := range s.Turns {
+		if t == turn {
+			return true
+		}
+	}
+	return false
+}
+
+// shocksOn returns the scenario's shocks that can fire on turn n
+func (s Scenario) shocksOn(turn int) []Shock {
+	var shocks []Shock
+	for _, shock := range s.Shocks {
+		if shock.canFireOnTurn(turn) {
+			shocks = append(shocks, shock)
+		}
+	}
+	return shocks
+}
+
+// Nature is the step at the start of a turn that draws each of the turn's
+// shocks, with roll returning uniform numbers in [0, 1). Shocks without a
+// probability have it estimated by the model from the world state. The
+// events of the shocks that fire are added to the returned world state,
+// which the actors then observe.
+func Nature(ctx context.Context, turn Turn, worldState WorldState, roll func() float64, backend LLMBackend) ([]ShockDraw, WorldState, error) {
+	var draws []ShockDraw
+	var estimate []Shock
+	for _, shock := range turn.Shocks {
+		if shock.Probability == nil {
+			estimate = append(estimate, shock)
+		}
+	}
+	estimates := make(map[string]ShockEstimate)
+	if len(estimate) > 0 {
+		estimated, err := EstimateShockProbabilities(ctx, estimate, worldState, turn, backend)
+		if err != nil {
+			return nil, worldState, fmt.Errorf("failed to estimate shock probabilities: %v", err)
+		}
+		for _, e := range estimated {
+			estimates[strings.ToLower(strings.TrimSpace(e.Shock))] = e
+		}
+	}
+
+	events := append([]string(nil), worldState.Events...)
+	for _, shock := range turn.Shocks {
+		draw := ShockDraw{Shock: shock.Name, Turn: turn.Number}
+		if shock.Probability != nil {
+			draw.Probability = *shock.Probability
+		} else {
+			e, ok := estimates[strings.ToLower(strings.TrimSpace(shock.Name))]
+			if !ok {
+				return nil, worldState, fmt.Errorf("no probability estimated for shock %q", shock.Name)
+			}
+			draw.Probability = math.Min(math.Max(e.Probability, 0), 1)
+			draw.Estimated = true
+			draw.Reasoning = e.Reasoning
+		}
+		draw.Fired = roll() < draw.Probability
+		if draw.Fired {
+			event := shock.Event
+			if turn.Date != "" {
+				event = fmt.Sprintf("[%s] %s", turn.Date, event)
+			}
+			events = append(events, event)
+		}
+		draws = append(draws, draw)
+	}
+	worldState.Events = events
+	return draws, worldState, nil
+}
+
+// shockRolls returns the rolls for nature's draws on a turn of a simulation.
+// They only depend on the scenario's seed, the simulation and the turn, so a
+// saved simulation draws the same shocks when it is replayed or resumed.
+func shockRolls(seed int64, simulation string, turn int) func() float64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d/%s/%d", seed, simulation, turn)
+	return rand.New(rand.NewSource(int64(h.Sum64()))).Float64
+}
+
+// seeded returns the scenario with a random seed, unless it already has one
+func (s Scenario) seeded() Scenario {
+	for s.Seed == 0 {
+		s.Seed = rand.Int63()
+	}
+	return s
+}
+
+// logShocks writes the shocks that fired, and those that didn't, to the log
+func logShocks(logger SimLogger, draws []ShockDraw) {
+	for _, draw := range draws {
+		if draw.Fired {
+			logger.Printf("\nShock: %s fired (probability %.2f)\n", draw.Shock, draw.Probability)
+		} else {
+			logger.Printf("\nShock: %s did not fire (probability %.2f)\n", draw.Shock, draw.Probability)
+		}
+	}
+}
+
+// EstimateShockProbabilities asks the model how likely each shock is to
+// happen during the turn, given the world state
+func EstimateShockProbabilities(ctx context.Context, shocks []Shock, worldState WorldState, turn Turn, backend LLMBackend) ([]ShockEstimate, error) {
+	if verbose {
+		log.Printf("[EstimateShockProbabilities] Estimating the probabilities of %d shocks", len(shocks))
+	}
+
+	worldStateJSON, err := json.Marshal(worldState)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal world state: %v", err)
+	}
+	var lines []string
+	for _, shock := range shocks {
+		lines = append(lines, fmt.Sprintf("- %s: %s", shock.Name, shock.Event))
+	}
+
+	period := "the next turn of the simulation"
+	if turn.Date != "" {
+		period = fmt.Sprintf("the period from %s to %s", turn.Date, turn.EndDate)
+	}
+	prompt := fmt.Sprintf(`Given this world state: %s
+
+These exogenous events may happen, independently of what any actor does:
+%s
+
+For each of them, estimate the probability that it happens during %s, given the world state and base rates for such events. Return a JSON object with:
+- estimates: one entry per event, each with:
+  - shock: the name of the event, exactly as given
+  - probability: between 0 and 1
+  - reasoning: the base rate and what in the world state makes the event more or less likely`, string(worldStateJSON), strings.Join(lines, "\n"), period)
+
+	var shockEstimates ShockEstimates
+	schema, err := jsonschema.GenerateSchemaForType(shockEstimates)
+	if err != nil {
+		return nil, fmt.Errorf("schema generation failed: %v", err)
+	}
+
+	openai_schema := openai.ChatCompletionResponseFormatJSONSchema{
+		Name:   "ShockEstimates",
+		Schema: schema,
+		Strict: true,
+	}
+
+	openai_json, _, err := backend.FetchJSON(ctx, LLMRequest{Prompt: prompt, Model: models.Nature, Schema: openai_schema, Stage: StageNature})
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal([]byte(openai_json), &shockEstimates)
+	if err != nil {
+		return nil, err
+	}
+
+	if verbose {
+		log.Printf("[EstimateShockProbabilities] Shock probabilities estimated")
+	}
+	return shockEstimates.Estimates, nil
+}
+
+// loadShockDraws reads the draws saved in simDir's turns, from the first turn
+// to the last complete one
+func loadShockDraws(simDir string) []ShockDraw {
+	var draws []ShockDraw
+	for turn := 1; ; turn++ {
+		turnDir := filepath.Join(simDir, fmt.Sprintf("turn_%d", turn))
+		var worldState WorldState
+		if err := readJSON(filepath.Join(turnDir, "world_state.json"), &worldState); err != nil {
+			return draws
+		}
+		var turnDraws []ShockDraw
+		if err := readJSON(filepath.Join(turnDir, "shocks.json"), &turnDraws); err == nil {
+			draws = append(draws, turnDraws...)
+		}
+	}
+}
+
+// fired reports whether the named shock fired on any of the draws
+func fired(draws []ShockDraw, shock string) bool {
+	for _, draw := range draws {
+		if draw.Shock == shock && draw.Fired {
+			return true
+		}
+	}
+	return false
+}
+
+// ShockBreakdown splits the answers of a batch by whether a shock fired
+type ShockBreakdown struct {
+	Shock           string              `json:"shock"`
+	Fired           int                 `json:"fired"` // Simulations it fired in, on at least one turn
+	NotFired        int                 `json:"not_fired"`
+	FiredPercentage float64             `json:"fired_percentage"`
+	IfFired         []QuestionAggregate `json:"if_fired"`
+	IfNotFired      []QuestionAggregate `json:"if_not_fired"`
+}
+
+// breakDownByShock aggregates the answers separately over the simulations
+// where each shock fired and those where it didn't. draws and results hold
+// one element per simulation; failed simulations have nil results.
+func breakDownByShock(shocks []Shock, questions []Question, draws [][]ShockDraw, results [][]SimulationResult) []ShockBreakdown {
+	var breakdowns []ShockBreakdown
+	for _, shock := range shocks {
+		var ifFired, ifNotFired [][]SimulationResult
+		for i, simulationResults := range results {
+			if simulationResults == nil {
+				continue
+			}
+			if fired(draws[i], shock.Name) {
+				ifFired = append(ifFired, simulationResults)
+			} else {
+				ifNotFired = append(ifNotFired, simulationResults)
+			}
+		}
+		breakdown := ShockBreakdown{
+			Shock:      shock.Name,
+			Fired:      len(ifFired),
+			NotFired:   len(ifNotFired),
+			IfFired:    aggregateAnswers(questions, ifFired),
+			IfNotFired: aggregateAnswers(questions, ifNotFired),
+		}
+		if total := len(ifFired) + len(ifNotFired); total > 0 {
+			breakdown.FiredPercentage = float64(len(ifFired)) / float64(total) * 100
+		}
+		breakdowns = append(breakdowns, breakdown)
+	}
+	return breakdowns
+}
+
+// headline is a one-line summary of a question's answers, e.g. "70.0% yes"
+// or "median 0.75 %"
+func headline(aggregate QuestionAggregate) string {
+	switch aggregate.Type {
+	case QuestionNumeric:
+		if aggregate.Numeric == nil {
+			return "no answers"
+		}
+		return strings.TrimSpace(fmt.Sprintf("median %g %s", aggregate.Numeric.Median, aggregate.Numeric.Unit))
+	case QuestionCategorical:
+		best := OptionCount{}
+		for _, option := range aggregate.Options {
+			if option.Count > best.Count {
+				best = option
+			}
+		}
+		if best.Count == 0 {
+			return "no answers"
+		}
+		return fmt.Sprintf("mostly %s (%.1f%%)", best.Option, best.Percentage)
+	case QuestionDate:
+		if aggregate.Dates == nil {
+			return "no answers"
+		}
+		return "median " + aggregate.Dates.Median
+	default:
+		if aggregate.YesCount+aggregate.NoCount == 0 {
+			return "no resolved answers"
+		}
+		return fmt.Sprintf("%.1f%% yes", aggregate.YesPercentage)
+	}
+}
+
+// printShockBreakdown writes how often a shock fired, and how the answers
+// differ with and without it, to the console
+func printShockBreakdown(breakdown ShockBreakdown) {
+	fmt.Printf("\nShock: %s fired in %d of %d simulations (%.1f%%)\n", breakdown.Shock, breakdown.Fired, breakdown.Fired+breakdown.NotFired, breakdown.FiredPercentage)
+	for i := range breakdown.IfFired {
+		fmt.Printf("  %s\n    fired: %s; not fired: %s\n", breakdown.IfFired[i].Question, headline(breakdown.IfFired[i]), headline(breakdown.IfNotFired[i]))
+	}
+}
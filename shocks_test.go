@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNature(t *testing.T) {
+	tests := []struct {
+		name      string
+		shocks    []Shock
+		estimates string
+		roll      float64
+		wantFired []bool
+		wantErr   string
+	}{
+		{
+			name:      "fixed probabilities",
+			shocks:    []Shock{{Name: "CPI", Event: "bad CPI", Probability: floatPtr(0.3)}, {Name: "Quake", Event: "earthquake", Probability: floatPtr(0.1)}},
+			roll:      0.2,
+			wantFired: []bool{true, false},
+		},
+		{
+			name:      "never and always",
+			shocks:    []Shock{{Name: "Never", Event: "e", Probability: floatPtr(0)}, {Name: "Always", Event: "e", Probability: floatPtr(1)}},
+			roll:      0,
+			wantFired: []bool{false, true},
+		},
+		{
+			name:      "estimated",
+			shocks:    []Shock{{Name: "CPI", Event: "bad CPI"}, {Name: "Quake", Event: "earthquake", Probability: floatPtr(0)}},
+			estimates: `{"estimates": [{"shock": "cpi", "probability": 0.8, "reasoning": "inflation is high"}]}`,
+			roll:      0.5,
+			wantFired: []bool{true, false},
+		},
+		{
+			name:      "estimate missing",
+			shocks:    []Shock{{Name: "CPI", Event: "bad CPI"}},
+			estimates: `{"estimates": []}`,
+			wantErr:   `no probability estimated for shock "CPI"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := NewFakeBackend()
+			if tt.estimates != "" {
+				fake.Responses["ShockEstimates"] = []string{tt.estimates}
+			}
+			worldState := WorldState{Events: []string{"before"}}
+			turn := Turn{Number: 1, Shocks: tt.shocks}
+			draws, shocked, err := Nature(context.Background(), turn, worldState, func() float64 { return tt.roll }, fake)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Nature returned error: %v", err)
+			}
+
+			wantEvents := []string{"before"}
+			for i, draw := range draws {
+				if draw.Fired != tt.wantFired[i] {
+					t.Errorf("%s fired = %v, want %v", draw.Shock, draw.Fired, tt.wantFired[i])
+				}
+				if draw.Fired {
+					wantEvents = append(wantEvents, tt.shocks[i].Event)
+				}
+				if draw.Estimated != (tt.shocks[i].Probability == nil) {
+					t.Errorf("%s estimated = %v", draw.Shock, draw.Estimated)
+				}
+			}
+			if strings.Join(shocked.Events, "|") != strings.Join(wantEvents, "|") {
+				t.Errorf("events = %q, want %q", shocked.Events, wantEvents)
+			}
+			if len(worldState.Events) != 1 {
+				t.Errorf("Nature changed the events of the world state it was given")
+			}
+		})
+	}
+}
+
+func TestShocksBeforeActorsObserve(t *testing.T) {
+	fake := NewFakeBackend()
+	scenario := testScenario(2)
+	scenario.StartDate, scenario.TurnDuration = "2026-01-01", "1 month"
+	scenario.Shocks = []Shock{
+		{Name: "Earthquake", Event: "A major earthquake hits Tokyo", Probability: floatPtr(1), Turns: []int{2}},
+		{Name: "CPI", Event: "CPI comes in far above expectations", Probability: floatPtr(0)},
+	}
+	dir := t.TempDir()
+
+	if _, err := runSingleSimulation(context.Background(), scenario, fake, dir, discardLogger{}); err != nil {
+		t.Fatalf("runSingleSimulation returned error: %v", err)
+	}
+
+	var turn1, turn2 []ShockDraw
+	readJSONFile(t, filepath.Join(dir, "turn_1", "shocks.json"), &turn1)
+	readJSONFile(t, filepath.Join(dir, "turn_2", "shocks.json"), &turn2)
+	if len(turn1) != 1 || turn1[0].Shock != "CPI" || turn1[0].Fired {
+		t.Errorf("turn 1 draws = %+v, want only CPI, not fired", turn1)
+	}
+	if len(turn2) != 2 || !turn2[0].Fired || turn2[1].Fired {
+		t.Errorf("turn 2 draws = %+v, want the earthquake to fire and CPI not to", turn2)
+	}
+
+	// Every actor observes the shock of its turn
+	observed := 0
+	for _, call := range fake.Calls() {
+		if call.Schema.Name == "ActorView" && strings.Contains(call.Prompt, "[2026-02-01] A major earthquake hits Tokyo") {
+			observed++
+		}
+	}
+	if observed != 2 {
+		t.Errorf("%d actors observed the earthquake, want 2", observed)
+	}
+
+	draws := loadShockDraws(dir)
+	if len(draws) != 3 || !fired(draws, "Earthquake") || fired(draws, "CPI") {
+		t.Errorf("loaded draws = %+v", draws)
+	}
+}
+
+func TestBreakDownByShock(t *testing.T) {
+	questions := []Question{{Text: "q?"}}
+	answer := func(yes bool) []SimulationResult {
+		return []SimulationResult{{Question: "q?", Type: QuestionBinary, YesNo: yes}}
+	}
+	draws := [][]ShockDraw{
+		{{Shock: "Quake", Turn: 1, Fired: true}},
+		{{Shock: "Quake", Turn: 1}, {Shock: "Quake", Turn: 2, Fired: true}},
+		{{Shock: "Quake", Turn: 1}, {Shock: "Quake", Turn: 2}},
+		nil, // Failed
+	}
+	results := [][]SimulationResult{answer(true), answer(true), answer(false), nil}
+
+	breakdowns := breakDownByShock([]Shock{{Name: "Quake"}}, questions, draws, results)
+	if len(breakdowns) != 1 {
+		t.Fatalf("got %d breakdowns, want 1", len(breakdowns))
+	}
+	b := breakdowns[0]
+	if b.Fired != 2 || b.NotFired != 1 {
+		t.Errorf("fired %d, not fired %d; want 2 and 1", b.Fired, b.NotFired)
+	}
+	if b.IfFired[0].YesPercentage != 100 || b.IfNotFired[0].YesPercentage != 0 {
+		t.Errorf("yes percentages = %v if fired, %v if not; want 100 and 0", b.IfFired[0].YesPercentage, b.IfNotFired[0].YesPercentage)
+	}
+	if got := headline(b.IfFired[0]); got != "100.0% yes" {
+		t.Errorf("headline = %q", got)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// HistogramBin counts the values in [Low, High). The last bin also includes High.
+type HistogramBin struct {
+	Low   float64 `json:"low"`
+	High  float64 `json:"high"`
+	Count int     `json:"count"`
+}
+
+// quantile returns the q-th quantile (0 <= q <= 1) of sorted values, linearly
+// interpolating between neighbouring values
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	position := q * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	upper := int(math.Ceil(position))
+	fraction := position - float64(lower)
+	return sorted[lower] + fraction*(sorted[upper]-sorted[lower])
+}
+
+// mean returns the arithmetic mean of values
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
+
+// histogram splits [low, high] into numBins equal bins and counts values in
+// each. Values outside the range are counted in the first or last bin.
+func histogram(values []float64, low float64, high float64, numBins int) []HistogramBin {
+	if numBins < 1 {
+		numBins = 1
+	}
+	if high <= low {
+		// All values are equal, or there is no range to split
+		return []HistogramBin{{Low: low, High: high, Count: len(values)}}
+	}
+
+	width := (high - low) / float64(numBins)
+	bins := make([]HistogramBin, numBins)
+	for i := range bins {
+		bins[i].Low = low + float64(i)*width
+		bins[i].High = low + float64(i+1)*width
+	}
+	bins[numBins-1].High = high
+
+	for _, value := range values {
+		i := int((value - low) / width)
+		if i < 0 {
+			i = 0
+		}
+		if i >= numBins {
+			i = numBins - 1
+		}
+		bins[i].Count++
+	}
+	return bins
+}
+
+// sortedCopy returns values in ascending order without modifying them
+func sortedCopy(values []float64) []float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return sorted
+}
+
+// Interval is a confidence or credible interval, in the same units as the
+// estimate it belongs to
+type Interval struct {
+	Low   float64 `json:"low"`
+	High  float64 `json:"high"`
+	Level float64 `json:"level"` // e.g. 0.95
+}
+
+// Width returns High - Low
+func (i Interval) Width() float64 {
+	return i.High - i.Low
+}
+
+// zScore returns the two-sided standard normal quantile for a confidence
+// level, e.g. 1.96 for 0.95
+func zScore(level float64) float64 {
+	return math.Sqrt2 * math.Erfinv(level)
+}
+
+// wilsonInterval is the Wilson score interval for a proportion of successes
+// out of n trials. Successes can be fractional, to project intervals for
+// larger n at the same proportion.
+func wilsonInterval(successes float64, n float64, level float64) Interval {
+	if n <= 0 {
+		return Interval{Low: 0, High: 1, Level: level}
+	}
+	z := zScore(level)
+	p := successes / n
+	denominator := 1 + z*z/n
+	center := (p + z*z/(2*n)) / denominator
+	halfWidth := z * math.Sqrt(p*(1-p)/n+z*z/(4*n*n)) / denominator
+	return Interval{Low: math.Max(0, center-halfWidth), High: math.Min(1, center+halfWidth), Level: level}
+}
+
+// betaInterval is the equal-tailed credible interval of a proportion under a
+// Jeffreys Beta(1/2, 1/2) prior, i.e. of Beta(successes+1/2, failures+1/2)
+func betaInterval(successes int, n int, level float64) Interval {
+	a := float64(successes) + 0.5
+	b := float64(n-successes) + 0.5
+	tail := (1 - level) / 2
+	return Interval{Low: betaQuantile(tail, a, b), High: betaQuantile(1-tail, a, b), Level: level}
+}
+
+// betaQuantile inverts the Beta(a, b) distribution function by bisection
+func betaQuantile(q float64, a float64, b float64) float64 {
+	low, high := 0.0, 1.0
+	for i := 0; i < 100; i++ {
+		mid := (low + high) / 2
+		if regularizedIncompleteBeta(mid, a, b) < q {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return (low + high) / 2
+}
+
+// regularizedIncompleteBeta is the distribution function of Beta(a, b) at x,
+// computed with a continued fraction (Numerical Recipes, section 6.4)
+func regularizedIncompleteBeta(x float64, a float64, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lgammaA, _ := math.Lgamma(a)
+	lgammaB, _ := math.Lgamma(b)
+	lgammaAB, _ := math.Lgamma(a + b)
+	front := math.Exp(lgammaAB - lgammaA - lgammaB + a*math.Log(x) + b*math.Log(1-x))
+	// The continued fraction converges quickly only on this side of the mean
+	if x > (a+1)/(a+b+2) {
+		return 1 - front*betaContinuedFraction(1-x, b, a)/b
+	}
+	return front * betaContinuedFraction(x, a, b) / a
+}
+
+func betaContinuedFraction(x float64, a float64, b float64) float64 {
+	const tiny = 1e-300
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	result := d
+	for m := 1.0; m <= 300; m++ {
+		// Even step
+		numerator := m * (b - m) * x / ((a + 2*m - 1) * (a + 2*m))
+		d = 1 + numerator*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + numerator/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		result *= d * c
+
+		// Odd step
+		numerator = -(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 2*m + 1))
+		d = 1 + numerator*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + numerator/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		result *= delta
+		if math.Abs(delta-1) < 1e-12 {
+			break
+		}
+	}
+	return result
+}
+
+// trialsForWidth returns the smallest number of trials, at least n, for which
+// the Wilson interval of proportion p is at most width wide
+func trialsForWidth(p float64, n int, width float64, level float64) int {
+	fits := func(trials int) bool {
+		return wilsonInterval(p*float64(trials), float64(trials), level).Width() <= width
+	}
+	if n < 1 {
+		n = 1
+	}
+	if fits(n) {
+		return n
+	}
+	// Double until wide enough, then bisect
+	low, high := n, 2*n
+	for !fits(high) {
+		low, high = high, 2*high
+	}
+	for high-low > 1 {
+		mid := (low + high) / 2
+		if fits(mid) {
+			high = mid
+		} else {
+			low = mid
+		}
+	}
+	return high
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQuantile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4}
+	tests := []struct {
+		q    float64
+		want float64
+	}{
+		{0, 1},
+		{1, 4},
+		{0.5, 2.5},
+		{1.0 / 3, 2},
+	}
+	for _, tt := range tests {
+		if got := quantile(sorted, tt.q); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("quantile(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+	if !math.IsNaN(quantile(nil, 0.5)) {
+		t.Errorf("quantile of no values should be NaN")
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		low    float64
+		high   float64
+		bins   int
+		want   []int
+	}{
+		{"even split", []float64{0, 1, 2, 3}, 0, 4, 2, []int{2, 2}},
+		{"high edge goes in last bin", []float64{0, 4}, 0, 4, 4, []int{1, 0, 0, 1}},
+		{"out of range values are clamped", []float64{-1, 5}, 0, 4, 2, []int{1, 1}},
+		{"no range", []float64{2, 2, 2}, 2, 2, 5, []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bins := histogram(tt.values, tt.low, tt.high, tt.bins)
+			if len(bins) != len(tt.want) {
+				t.Fatalf("got %d bins, want %d", len(bins), len(tt.want))
+			}
+			for i, bin := range bins {
+				if bin.Count != tt.want[i] {
+					t.Errorf("bin %d has %d values, want %d", i, bin.Count, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWilsonInterval(t *testing.T) {
+	tests := []struct {
+		successes, n float64
+		wantLow      float64
+		wantHigh     float64
+	}{
+		{7, 10, 0.3968, 0.8922},
+		{0, 10, 0, 0.2775},
+		{10, 10, 0.7225, 1},
+		{50, 100, 0.4038, 0.5962},
+	}
+	for _, tt := range tests {
+		got := wilsonInterval(tt.successes, tt.n, 0.95)
+		if math.Abs(got.Low-tt.wantLow) > 1e-3 || math.Abs(got.High-tt.wantHigh) > 1e-3 {
+			t.Errorf("wilsonInterval(%v, %v) = [%.4f, %.4f], want [%.4f, %.4f]", tt.successes, tt.n, got.Low, got.High, tt.wantLow, tt.wantHigh)
+		}
+	}
+	if got := wilsonInterval(0, 0, 0.95); got.Low != 0 || got.High != 1 {
+		t.Errorf("interval without trials = %+v, want [0, 1]", got)
+	}
+}
+
+func TestBetaInterval(t *testing.T) {
+	tests := []struct {
+		successes, n int
+		wantLow      float64
+		wantHigh     float64
+	}{
+		{7, 10, 0.3942, 0.9073},
+		{0, 10, 0, 0.2172},
+		{5, 10, 0.2235, 0.7765},
+	}
+	for _, tt := range tests {
+		got := betaInterval(tt.successes, tt.n, 0.95)
+		if math.Abs(got.Low-tt.wantLow) > 1e-3 || math.Abs(got.High-tt.wantHigh) > 1e-3 {
+			t.Errorf("betaInterval(%d, %d) = [%.4f, %.4f], want [%.4f, %.4f]", tt.successes, tt.n, got.Low, got.High, tt.wantLow, tt.wantHigh)
+		}
+	}
+}
+
+func TestTrialsForWidth(t *testing.T) {
+	tests := []struct {
+		p     float64
+		n     int
+		width float64
+	}{
+		{0.5, 10, 0.1},
+		{0.9, 10, 0.1},
+		{1, 3, 0.6},
+		{0.5, 1000, 0.1}, // Already narrow enough
+	}
+	for _, tt := range tests {
+		got := trialsForWidth(tt.p, tt.n, tt.width, 0.95)
+		width := func(trials int) float64 {
+			return wilsonInterval(tt.p*float64(trials), float64(trials), 0.95).Width()
+		}
+		if got < tt.n || width(got) > tt.width || (got > tt.n && width(got-1) <= tt.width) {
+			t.Errorf("trialsForWidth(%v, %d, %v) = %d, not the fewest trials for the width", tt.p, tt.n, tt.width, got)
+		}
+	}
+	// The normal approximation needs about 384 trials at p = 0.5
+	if got := trialsForWidth(0.5, 1, 0.1, 0.95); got < 370 || got > 390 {
+		t.Errorf("trialsForWidth(0.5, 1, 0.1) = %d, want about 384", got)
+	}
+}
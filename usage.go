@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// TokenUsage counts the tokens billed for one or more LLM calls
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"` // Includes reasoning tokens
+	ReasoningTokens  int `json:"reasoning_tokens"`
+}
+
+func (u TokenUsage) Total() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// ModelPrice is what a model costs, in US dollars per million tokens
+type ModelPrice struct {
+	Input  float64
+	Output float64 // Completion tokens, reasoning tokens included
+}
+
+// https://openai.com/api/pricing/
+var modelPrices = map[string]ModelPrice{
+	GPT3_5_turbo: {Input: 0.50, Output: 1.50},
+	GPT4_o:       {Input: 5.00, Output: 15.00},
+	GPT4_turbo:   {Input: 10.00, Output: 30.00},
+	GPT4_o_mini:  {Input: 0.15, Output: 0.60},
+	GPT5_mini:    {Input: 0.25, Output: 2.00},
+	GPT5:         {Input: 1.25, Output: 10.00},
+	GPT5_2:       {Input: 1.75, Output: 14.00},
+}
+
+// Cost returns what the usage cost with the given model, or false if the
+// model has no known price (e.g. a local model)
+func (u TokenUsage) Cost(model string) (float64, bool) {
+	price, ok := modelPrices[model]
+	if !ok {
+		return 0, false
+	}
+	return (float64(u.PromptTokens)*price.Input + float64(u.CompletionTokens)*price.Output) / 1e6, true
+}
+
+// UsageTotals sums the usage and cost of a number of calls
+type UsageTotals struct {
+	Calls int `json:"calls"`
+	TokenUsage
+	CostUSD       float64 `json:"cost_usd"`
+	UnpricedCalls int     `json:"unpriced_calls,omitempty"` // Calls to models missing from modelPrices, not in CostUSD
+}
+
+func (t *UsageTotals) add(other UsageTotals) {
+	t.Calls += other.Calls
+	t.PromptTokens += other.PromptTokens
+	t.CompletionTokens += other.CompletionTokens
+	t.ReasoningTokens += other.ReasoningTokens
+	t.CostUSD += other.CostUSD
+	t.UnpricedCalls += other.UnpricedCalls
+}
+
+// UsageCall is one LLM call, attributed to the stage (as in ModelConfig) and,
+// for actor views and actions, the actor it was made for
+type UsageCall struct {
+	Stage string `json:"stage"`
+	Actor string `json:"actor,omitempty"`
+	Model string `json:"model"`
+	TokenUsage
+	CostUSD float64 `json:"cost_usd"`
+	Priced  bool    `json:"priced"`
+}
+
+func (c UsageCall) totals() UsageTotals {
+	totals := UsageTotals{Calls: 1, TokenUsage: c.TokenUsage, CostUSD: c.CostUSD}
+	if !c.Priced {
+		totals.UnpricedCalls = 1
+	}
+	return totals
+}
+
+// UsageReport is what gets saved to usage.json, for a simulation or, without
+// the individual calls, for a whole batch
+type UsageReport struct {
+	Total   UsageTotals            `json:"total"`
+	ByStage map[string]UsageTotals `json:"by_stage"`
+	ByModel map[string]UsageTotals `json:"by_model"`
+	ByActor map[string]UsageTotals `json:"by_actor,omitempty"`
+	Calls   []UsageCall            `json:"calls,omitempty"`
+}
+
+func addTo(totals map[string]UsageTotals, key string, other UsageTotals) map[string]UsageTotals {
+	if totals == nil {
+		totals = make(map[string]UsageTotals)
+	}
+	sum := totals[key]
+	sum.add(other)
+	totals[key] = sum
+	return totals
+}
+
+func (r *UsageReport) record(call UsageCall) {
+	totals := call.totals()
+	r.Total.add(totals)
+	r.ByStage = addTo(r.ByStage, call.Stage, totals)
+	r.ByModel = addTo(r.ByModel, call.Model, totals)
+	if call.Actor != "" {
+		r.ByActor = addTo(r.ByActor, call.Actor, totals)
+	}
+	r.Calls = append(r.Calls, call)
+}
+
+// merge adds the totals of other, but not its individual calls
+func (r *UsageReport) merge(other UsageReport) {
+	r.Total.add(other.Total)
+	for stage, totals := range other.ByStage {
+		r.ByStage = addTo(r.ByStage, stage, totals)
+	}
+	for model, totals := range other.ByModel {
+		r.ByModel = addTo(r.ByModel, model, totals)
+	}
+	for actor, totals := range other.ByActor {
+		r.ByActor = addTo(r.ByActor, actor, totals)
+	}
+}
+
+// printUsage prints the totals of a report, broken down by stage
+func printUsage(report UsageReport) {
+	total := report.Total
+	fmt.Printf("\nLLM calls: %d (%d prompt + %d completion tokens, %d of them reasoning)\n", total.Calls, total.PromptTokens, total.CompletionTokens, total.ReasoningTokens)
+	fmt.Printf("Cost: $%.4f\n", total.CostUSD)
+	if total.UnpricedCalls > 0 {
+		fmt.Printf("(%d calls to models without a known price are not included)\n", total.UnpricedCalls)
+	}
+	stages := make([]string, 0, len(report.ByStage))
+	for stage := range report.ByStage {
+		stages = append(stages, stage)
+	}
+	sort.Strings(stages)
+	for _, stage := range stages {
+		totals := report.ByStage[stage]
+		fmt.Printf("  %s: %d calls, %d tokens, $%.4f\n", stage, totals.Calls, totals.Total(), totals.CostUSD)
+	}
+}
+
+// UsageRecorder is an LLMBackend that records the usage of every call it
+// passes on to another backend. Each simulation gets its own, so usage can be
+// attributed to it.
+type UsageRecorder struct {
+	backend LLMBackend
+
+	mu     sync.Mutex
+	report UsageReport
+}
+
+// NewUsageRecorder records usage on top of report, e.g. one loaded from the
+// usage.json of a simulation being resumed
+func NewUsageRecorder(backend LLMBackend, report UsageReport) *UsageRecorder {
+	return &UsageRecorder{backend: backend, report: report}
+}
+
+func (r *UsageRecorder) FetchJSON(ctx context.Context, req LLMRequest) (string, TokenUsage, error) {
+	content, usage, err := r.backend.FetchJSON(ctx, req)
+	if err != nil && usage.Total() == 0 {
+		return content, usage, err
+	}
+
+	cost, priced := usage.Cost(req.Model)
+	r.mu.Lock()
+	r.report.record(UsageCall{Stage: req.Stage, Actor: req.Actor, Model: req.Model, TokenUsage: usage, CostUSD: cost, Priced: priced})
+	r.mu.Unlock()
+	return content, usage, err
+}
+
+// Report returns the usage recorded so far
+func (r *UsageRecorder) Report() UsageReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var report UsageReport
+	report.merge(r.report)
+	report.Calls = append([]UsageCall(nil), r.report.Calls...)
+	return report
+}
+
+// saveUsage writes report to dir/usage.json
+func saveUsage(dir string, report UsageReport) {
+	usageJSON, _ := json.MarshalIndent(report, "", "  ")
+	ioutil.WriteFile(filepath.Join(dir, "usage.json"), usageJSON, 0644)
+}
+
+// loadUsage reads dir/usage.json, returning an empty report if there is none
+func loadUsage(dir string) UsageReport {
+	var report UsageReport
+	if err := readJSON(filepath.Join(dir, "usage.json"), &report); err != nil {
+		return UsageReport{}
+	}
+	return report
+}
+
+// batchUsage sums the usage.json of every simulation_N in baseDir, including
+// failed simulations and ones finished by an earlier run of the batch
+func batchUsage(baseDir string, numSimulations int) UsageReport {
+	var total UsageReport
+	for i := 1; i <= numSimulations; i++ {
+		total.merge(loadUsage(filepath.Join(baseDir, fmt.Sprintf("simulation_%d", i))))
+	}
+	return total
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestTokenUsageCost(t *testing.T) {
+	usage := TokenUsage{PromptTokens: 1000000, CompletionTokens: 500000, ReasoningTokens: 200000}
+	tests := []struct {
+		model      string
+		wantCost   float64
+		wantPriced bool
+	}{
+		{GPT5_2, 1.75 + 7.00, true},
+		{GPT4_o_mini, 0.15 + 0.30, true},
+		{"llama3.1", 0, false},
+	}
+	for _, tt := range tests {
+		cost, priced := usage.Cost(tt.model)
+		if priced != tt.wantPriced || math.Abs(cost-tt.wantCost) > 1e-9 {
+			t.Errorf("Cost(%q) = %v, %v; want %v, %v", tt.model, cost, priced, tt.wantCost, tt.wantPriced)
+		}
+	}
+}
+
+func TestUsageRecorderAttributesCalls(t *testing.T) {
+	fake := NewFakeBackend()
+	fake.Usage = TokenUsage{PromptTokens: 100, CompletionTokens: 20, ReasoningTokens: 5}
+	recorder := NewUsageRecorder(fake, UsageReport{})
+
+	actors := makeActors(2)
+	if _, _, _, err := RunSimulationTurn(context.Background(), Turn{Number: 1}, WorldState{Description: "world"}, actors, nil, nil, recorder, discardLogger{}); err != nil {
+		t.Fatalf("RunSimulationTurn returned error: %v", err)
+	}
+
+	report := recorder.Report()
+	// Two views, two actions and one update
+	if report.Total.Calls != 5 || report.Total.PromptTokens != 500 || report.Total.ReasoningTokens != 25 {
+		t.Errorf("unexpected total: %+v", report.Total)
+	}
+	if got := report.ByStage[StageFilter].Calls; got != 2 {
+		t.Errorf("filter calls = %d, want 2", got)
+	}
+	if got := report.ByStage[StageUpdate].Calls; got != 1 {
+		t.Errorf("update calls = %d, want 1", got)
+	}
+	for _, actor := range actors.Actors {
+		if got := report.ByActor[actor.Name].Calls; got != 2 {
+			t.Errorf("calls for %s = %d, want 2", actor.Name, got)
+		}
+	}
+	wantCost, _ := fake.Usage.Cost(models.Filter)
+	if got := report.ByModel[models.Filter].CostUSD; math.Abs(got-5*wantCost) > 1e-12 {
+		t.Errorf("cost = %v, want %v", got, 5*wantCost)
+	}
+	if len(report.Calls) != 5 {
+		t.Errorf("%d calls recorded, want 5", len(report.Calls))
+	}
+}
+
+func TestUsageRecorderCountsUnpricedModels(t *testing.T) {
+	defer func(saved ModelConfig) { models = saved }(models)
+	models = DefaultModelConfig("local-model")
+
+	fake := NewFakeBackend()
+	fake.Usage = TokenUsage{PromptTokens: 10, CompletionTokens: 10}
+	recorder := NewUsageRecorder(fake, UsageReport{})
+	if _, err := GetActors(context.Background(), "situation", recorder); err != nil {
+		t.Fatal(err)
+	}
+	if total := recorder.Report().Total; total.UnpricedCalls != 1 || total.CostUSD != 0 || total.PromptTokens != 10 {
+		t.Errorf("unexpected total: %+v", total)
+	}
+}
+
+func TestBatchSavesUsage(t *testing.T) {
+	baseDir := filepath.Join(t.TempDir(), "multi_sim_test")
+	fake := NewFakeBackend()
+	fake.Usage = TokenUsage{PromptTokens: 10, CompletionTokens: 1}
+
+	if err := runSimulationBatch(context.Background(), testScenario(2), 3, fake, baseDir); err != nil {
+		t.Fatalf("runSimulationBatch returned error: %v", err)
+	}
+
+	recorded := 0
+	for i := 1; i <= 3; i++ {
+		var usage UsageReport
+		readJSONFile(t, filepath.Join(baseDir, fmt.Sprintf("simulation_%d", i), "usage.json"), &usage)
+		if usage.Total.Calls == 0 || len(usage.Calls) != usage.Total.Calls {
+			t.Errorf("simulation %d: unexpected usage %+v", i, usage.Total)
+		}
+		recorded += usage.Total.Calls
+	}
+	if recorded != len(fake.Calls()) {
+		t.Errorf("simulations recorded %d calls, backend received %d", recorded, len(fake.Calls()))
+	}
+
+	var aggregate AggregateResult
+	readJSONFile(t, filepath.Join(baseDir, "aggregate_results.json"), &aggregate)
+	if aggregate.Usage.Total.Calls != len(fake.Calls()) || aggregate.Usage.Total.PromptTokens != 10*len(fake.Calls()) {
+		t.Errorf("unexpected aggregate usage: %+v", aggregate.Usage.Total)
+	}
+	if aggregate.Usage.Calls != nil {
+		t.Errorf("aggregate usage lists individual calls")
+	}
+
+	// Resuming keeps the usage of the earlier run
+	resumed := NewFakeBackend()
+	resumed.Usage = fake.Usage
+	if err := resumeMultipleSimulations(context.Background(), baseDir, testScenario(2), 4, resumed); err != nil {
+		t.Fatalf("resumeMultipleSimulations returned error: %v", err)
+	}
+	readJSONFile(t, filepath.Join(baseDir, "aggregate_results.json"), &aggregate)
+	if want := len(fake.Calls()) + len(resumed.Calls()); aggregate.Usage.Total.Calls != want {
+		t.Errorf("aggregate calls after resume = %d, want %d", aggregate.Usage.Total.Calls, want)
+	}
+}
+
+func TestOpenAIBackendReportsUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"choices": [{"message": {"role": "assistant", "content": "{\"answer\": \"a\", \"yes_no\": true}"}}],
+			"usage": {"prompt_tokens": 120, "completion_tokens": 80, "total_tokens": 200, "completion_tokens_details": {"reasoning_tokens": 50}}
+		}`))
+	}))
+	defer server.Close()
+
+	backend := NewOpenAIBackend("test", server.URL, nil)
+	_, usage, err := backend.FetchJSON(context.Background(), LLMRequest{Prompt: "question", Model: GPT5_2})
+	if err != nil {
+		t.Fatalf("FetchJSON returned error: %v", err)
+	}
+	if want := (TokenUsage{PromptTokens: 120, CompletionTokens: 80, ReasoningTokens: 50}); usage != want {
+		t.Errorf("usage = %+v, want %+v", usage, want)
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+// Variable is a quantity, such as a policy rate or an approval rating, that a
+// scenario tracks through the world state alongside its events
+type Variable struct {
+	Name        string   `json:"name"`
+	Unit        string   `json:"unit,omitempty"`
+	Initial     float64  `json:"initial"`
+	Min         *float64 `json:"min,omitempty"`
+	Max         *float64 `json:"max,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+// VariableValue is the value of a variable at one point of a simulation
+type VariableValue struct {
+	Name          string  `json:"name"`
+	Value         float64 `json:"value"`
+	Justification string  `json:"justification"` // Why it has this value, or changed to it in the last turn
+}
+
+// validate checks that the variable has a name and that its bounds hold its
+// initial value
+func (v Variable) validate() error {
+	if strings.TrimSpace(v.Name) == "" {
+		return fmt.Errorf("variable without a name")
+	}
+	if v.Min != nil && v.Max != nil && *v.Min > *v.Max {
+		return fmt.Errorf("variable %q has min greater than max", v.Name)
+	}
+	if v.clamp(v.Initial) != v.Initial {
+		return fmt.Errorf("variable %q starts outside its bounds", v.Name)
+	}
+	return nil
+}
+
+// clamp keeps a value within the variable's bounds
+func (v Variable) clamp(value float64) float64 {
+	if v.Min != nil {
+		value = math.Max(value, *v.Min)
+	}
+	if v.Max != nil {
+		value = math.Min(value, *v.Max)
+	}
+	return value
+}
+
+// findVariable looks up a variable by name, ignoring case
+func findVariable(variables []Variable, name string) (Variable, bool) {
+	for _, variable := range variables {
+		if strings.EqualFold(variable.Name, strings.TrimSpace(name)) {
+			return variable, true
+		}
+	}
+	return Variable{}, false
+}
+
+// findValue looks up the value of a variable by name, ignoring case
+func findValue(values []VariableValue, name string) (VariableValue, bool) {
+	for _, value := range values {
+		if strings.EqualFold(value.Name, strings.TrimSpace(name)) {
+			return value, true
+		}
+	}
+	return VariableValue{}, false
+}
+
+// initialValues are the values of the variables before the first turn
+func initialValues(variables []Variable) []VariableValue {
+	values := []VariableValue{}
+	for _, variable := range variables {
+		values = append(values, VariableValue{Name: variable.Name, Value: variable.Initial, Justification: "Initial value"})
+	}
+	return values
+}
+
+// reconcileVariables checks the values the model gave after a turn against
+// the declared variables. Every declared variable gets exactly one value, in
+// declaration order: a missing one keeps its previous value, and one out of
+// bounds is clamped. Values of undeclared variables are dropped.
+func reconcileVariables(declared []Variable, previous []VariableValue, updated []VariableValue) []VariableValue {
+	values := []VariableValue{}
+	for _, variable := range declared {
+		value, ok := findValue(updated, variable.Name)
+		if !ok {
+			value, _ = findValue(previous, variable.Name)
+			value.Justification = "Not updated this turn"
+		}
+		value.Name = variable.Name
+		if clamped := variable.clamp(value.Value); clamped != value.Value {
+			value.Justification += fmt.Sprintf(" (%g was clamped to the bounds)", value.Value)
+			value.Value = clamped
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// knownValues returns the values an actor knows, as named in its view, with
+// the world state's actual values, so views can't drift from the world
+func knownValues(view []VariableValue, world []VariableValue) []VariableValue {
+	known := []VariableValue{}
+	for _, value := range world {
+		if _, ok := findValue(view, value.Name); ok {
+			known = append(known, value)
+		}
+	}
+	return known
+}
+
+// describeVariables lists the variables with their units, bounds and
+// descriptions, for prompts
+func describeVariables(variables []Variable) string {
+	var lines []string
+	for _, variable := range variables {
+		line := "- " + variable.Name
+		if variable.Unit != "" {
+			line += fmt.Sprintf(" (%s)", variable.Unit)
+		}
+		if variable.Min != nil || variable.Max != nil {
+			low, high := "-inf", "inf"
+			if variable.Min != nil {
+				low = fmt.Sprint(*variable.Min)
+			}
+			if variable.Max != nil {
+				high = fmt.Sprint(*variable.Max)
+			}
+			line += fmt.Sprintf(", between %s and %s", low, high)
+		}
+		if variable.Description != "" {
+			line += ": " + variable.Description
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// loadVariableSeries reads the values of the variables in the world states
+// saved in simDir, from the initial one to the last complete turn
+func loadVariableSeries(simDir string) [][]VariableValue {
+	var worldState WorldState
+	if err := readJSON(filepath.Join(simDir, "initial_world_state.json"), &worldState); err != nil {
+		return nil
+	}
+	series := [][]VariableValue{worldState.Variables}
+	for turn := 1; ; turn++ {
+		var worldState WorldState
+		if err := readJSON(filepath.Join(simDir, fmt.Sprintf("turn_%d", turn), "world_state.json"), &worldState); err != nil {
+			return series
+		}
+		series = append(series, worldState.Variables)
+	}
+}
+
+// VariableTurn summarizes the values of a variable after one turn across
+// simulations; turn 0 is the initial value
+type VariableTurn struct {
+	Turn   int     `json:"turn"`
+	Count  int     `json:"count"`
+	Mean   float64 `json:"mean"`
+	P10    float64 `json:"p10"`
+	Median float64 `json:"median"`
+	P90    float64 `json:"p90"`
+}
+
+// VariableSeries summarizes a variable over the turns of a batch
+type VariableSeries struct {
+	Name  string         `json:"name"`
+	Unit  string         `json:"unit,omitempty"`
+	Turns []VariableTurn `json:"turns"`
+}
+
+// summarizeVariables summarizes each declared variable turn by turn, given
+// the series of values of each simulation
+func summarizeVariables(declared []Variable, runs [][][]VariableValue) []VariableSeries {
+	var summaries []VariableSeries
+	for _, variable := range declared {
+		summary := VariableSeries{Name: variable.Name, Unit: variable.Unit}
+		for turn := 0; ; turn++ {
+			var values []float64
+			for _, run := range runs {
+				if turn >= len(run) {
+					continue
+				}
+				if value, ok := findValue(run[turn], variable.Name); ok {
+					values = append(values, value.Value)
+				}
+			}
+			if len(values) == 0 {
+				break
+			}
+			sorted := sortedCopy(values)
+			summary.Turns = append(summary.Turns, VariableTurn{
+				Turn:   turn,
+				Count:  len(values),
+				Mean:   mean(values),
+				P10:    quantile(sorted, 0.10),
+				Median: quantile(sorted, 0.50),
+				P90:    quantile(sorted, 0.90),
+			})
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// Width in characters of variable plots
+const variablePlotWidth = 40
+
+// plotVariableSeries draws a variable over the turns as text, one row per
+// turn: the 10th to 90th percentile as dashes and the median as a star
+func plotVariableSeries(series VariableSeries) string {
+	if len(series.Turns) == 0 {
+		return fmt.Sprintf("%s: no values\n", series.Name)
+	}
+	low, high := series.Turns[0].P10, series.Turns[0].P90
+	for _, turn := range series.Turns {
+		low, high = math.Min(low, turn.P10), math.Max(high, turn.P90)
+	}
+	position := func(value float64) int {
+		if high == low {
+			return variablePlotWidth / 2
+		}
+		return int(math.Round((value - low) / (high - low) * (variablePlotWidth - 1)))
+	}
+
+	var b strings.Builder
+	name := series.Name
+	if series.Unit != "" {
+		name += fmt.Sprintf(" (%s)", series.Unit)
+	}
+	fmt.Fprintf(&b, "%s, from %g to %g:\n", name, low, high)
+	for _, turn := range series.Turns {
+		row := []byte(strings.Repeat(" ", variablePlotWidth))
+		for i := position(turn.P10); i <= position(turn.P90); i++ {
+			row[i] = '-'
+		}
+		row[position(turn.Median)] = '*'
+		fmt.Fprintf(&b, "  Turn %2d |%s| %g", turn.Turn, string(row), turn.Median)
+		if turn.P10 != turn.P90 {
+			fmt.Fprintf(&b, " (%g to %g)", turn.P10, turn.P90)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReconcileVariables(t *testing.T) {
+	declared := []Variable{
+		{Name: "Rate", Initial: 0.5, Min: floatPtr(0), Max: floatPtr(10)},
+		{Name: "Approval", Initial: 40},
+	}
+	previous := []VariableValue{{Name: "Rate", Value: 0.5, Justification: "Initial value"}, {Name: "Approval", Value: 40, Justification: "Initial value"}}
+
+	tests := []struct {
+		name    string
+		updated []VariableValue
+		want    []VariableValue
+	}{
+		{
+			name:    "updated",
+			updated: []VariableValue{{Name: "Approval", Value: 35, Justification: "scandal"}, {Name: "Rate", Value: 0.75, Justification: "hike"}},
+			want:    []VariableValue{{Name: "Rate", Value: 0.75, Justification: "hike"}, {Name: "Approval", Value: 35, Justification: "scandal"}},
+		},
+		{
+			name:    "missing keeps its value",
+			updated: []VariableValue{{Name: "rate", Value: 1, Justification: "hike"}},
+			want:    []VariableValue{{Name: "Rate", Value: 1, Justification: "hike"}, {Name: "Approval", Value: 40, Justification: "Not updated this turn"}},
+		},
+		{
+			name:    "out of bounds is clamped",
+			updated: []VariableValue{{Name: "Rate", Value: -1, Justification: "cut"}, {Name: "Approval", Value: 40, Justification: "same"}},
+			want:    []VariableValue{{Name: "Rate", Value: 0, Justification: "cut (-1 was clamped to the bounds)"}, {Name: "Approval", Value: 40, Justification: "same"}},
+		},
+		{
+			name:    "undeclared is dropped",
+			updated: []VariableValue{{Name: "Rate", Value: 0.5, Justification: "same"}, {Name: "Approval", Value: 40, Justification: "same"}, {Name: "Inflation", Value: 3, Justification: "made up"}},
+			want:    []VariableValue{{Name: "Rate", Value: 0.5, Justification: "same"}, {Name: "Approval", Value: 40, Justification: "same"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reconcileVariables(declared, previous, tt.updated); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reconcileVariables = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeAndPlotVariables(t *testing.T) {
+	declared := []Variable{{Name: "Rate", Unit: "%"}}
+	value := func(v float64) []VariableValue { return []VariableValue{{Name: "Rate", Value: v}} }
+	runs := [][][]VariableValue{
+		{value(1), value(2), value(3)},
+		{value(1), value(4)}, // Stopped after one turn
+	}
+
+	summaries := summarizeVariables(declared, runs)
+	if len(summaries) != 1 || len(summaries[0].Turns) != 3 {
+		t.Fatalf("summaries = %+v, want one variable over three turns", summaries)
+	}
+	turns := summaries[0].Turns
+	if turns[0].Median != 1 || turns[1].Median != 3 || turns[1].Count != 2 || turns[2].Count != 1 {
+		t.Errorf("turns = %+v", turns)
+	}
+
+	plot := plotVariableSeries(summaries[0])
+	if lines := strings.Split(strings.TrimSpace(plot), "\n"); len(lines) != 4 || !strings.HasPrefix(lines[0], "Rate (%)") {
+		t.Errorf("plot has unexpected lines:\n%s", plot)
+	}
+}
+
+func TestSimulationWithVariables(t *testing.T) {
+	fake := NewFakeBackend()
+	fake.Handler = func(req LLMRequest) (string, error) {
+		switch req.Schema.Name {
+		case "WorldState":
+			// Out of bounds, and with a variable the scenario doesn't declare
+			return `{"events": ["rates hiked"], "description": "d", "date": "", "variables": [{"name": "rate", "value": 12, "justification": "hikes"}, {"name": "Inflation", "value": 3, "justification": "made up"}]}`, nil
+		case "ActorView":
+			return `{"visible_events": ["rates hiked"], "interpretation": "i", "variables": [{"name": "Rate", "value": 99, "justification": "wrong"}]}`, nil
+		}
+		return defaultFakeResponses[req.Schema.Name][0], nil
+	}
+	rate := Variable{Name: "Rate", Unit: "%", Initial: 0.5, Min: floatPtr(0), Max: floatPtr(10)}
+	scenario := testScenario(2)
+	scenario.Variables = []Variable{rate}
+	scenario.Questions = []Question{Question{Text: "Rate?", Variable: "rate"}.readingVariable(rate)}
+	dir := t.TempDir()
+
+	results, err := runSingleSimulation(context.Background(), scenario, fake, dir, discardLogger{})
+	if err != nil {
+		t.Fatalf("runSingleSimulation returned error: %v", err)
+	}
+
+	// The model's initial values are replaced by the declared ones
+	var initial WorldState
+	readJSONFile(t, filepath.Join(dir, "initial_world_state.json"), &initial)
+	if len(initial.Variables) != 1 || initial.Variables[0].Value != 0.5 {
+		t.Errorf("initial variables = %+v, want Rate at 0.5", initial.Variables)
+	}
+	var final WorldState
+	readJSONFile(t, filepath.Join(dir, "turn_2", "world_state.json"), &final)
+	if len(final.Variables) != 1 || final.Variables[0].Name != "Rate" || final.Variables[0].Value != 10 {
+		t.Errorf("final variables = %+v, want Rate clamped to 10", final.Variables)
+	}
+
+	for _, call := range fake.Calls() {
+		switch call.Schema.Name {
+		case "WorldState":
+			if call.Stage == StageUpdate && !strings.Contains(call.Prompt, "- Rate (%), between 0 and 10") {
+				t.Errorf("update prompt doesn't declare the variables:\n%s", call.Prompt)
+			}
+		case "ActorAction":
+			// Views carry the world's value, not the filter model's
+			if strings.Contains(call.Prompt, `"value":99`) || !strings.Contains(call.Prompt, `"name":"Rate","value":`) {
+				t.Errorf("action prompt doesn't have the world's value of the variable:\n%s", call.Prompt)
+			}
+		case "NumericAnswer":
+			t.Errorf("a question reading a variable was asked of the model")
+		}
+	}
+
+	if results[1].Value == nil || *results[1].Value != 10 {
+		t.Errorf("answer to the variable question = %v, want 10", results[1].Value)
+	}
+
+	series := summarizeVariables(scenario.Variables, [][][]VariableValue{loadVariableSeries(dir)})
+	var medians []float64
+	for _, turn := range series[0].Turns {
+		medians = append(medians, turn.Median)
+	}
+	if want := []float64{0.5, 10, 10}; !reflect.DeepEqual(medians, want) {
+		t.Errorf("medians by turn = %v, want %v", medians, want)
+	}
+}